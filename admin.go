@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	echov4 "github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// CacheEntry describes a single cache key for the admin API. TTLSeconds is -1
+// for keys that never expire.
+type CacheEntry struct {
+	Key        string `json:"key"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+	Size       int    `json:"size"`
+}
+
+// CacheInspector is implemented by cache backends that can enumerate their keys.
+type CacheInspector interface {
+	Entries(prefix string) []CacheEntry
+}
+
+type InvalidateResponse struct {
+	Deleted []string `json:"deleted"`
+}
+
+type RefreshResponse struct {
+	Key  string `json:"key"`
+	Size int    `json:"size"`
+}
+
+// adminToken returns the bearer token protecting /admin. EVINCE_ADMIN_TOKEN
+// overrides the config value.
+func (s *Service) adminToken() string {
+	if token := os.Getenv("EVINCE_ADMIN_TOKEN"); token != "" {
+		return token
+	}
+	return s.Config.AdminToken
+}
+
+// configureAdminRoutes registers the /admin group. It is left out entirely when
+// no admin token is configured.
+func (s *Service) configureAdminRoutes() {
+	token := s.adminToken()
+	if token == "" {
+		s.Echo.Logger.Infof("admin api disabled: no admin token configured")
+		return
+	}
+
+	admin := s.Echo.Group("/admin", middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		Validator: func(key string, _ echov4.Context) (bool, error) {
+			return subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1, nil
+		},
+	}))
+
+	admin.GET("/cache", func(ctx echov4.Context) error {
+		return s.listCache(ctx, ctx.QueryParam("prefix"))
+	})
+
+	// keys may contain slashes, so they are taken from ?key= or the rest of the path
+	admin.DELETE("/cache", func(ctx echov4.Context) error {
+		key, prefix := ctx.QueryParam("key"), ctx.QueryParam("prefix")
+		switch {
+		case key != "" && prefix != "":
+			return invalidParameter(fmt.Errorf("key and prefix are mutually exclusive"))
+		case key != "":
+			return s.invalidateCacheKey(ctx, key)
+		case prefix != "":
+			return s.invalidateCachePrefix(ctx, prefix)
+		}
+		return invalidParameter(fmt.Errorf("key or prefix is required"))
+	})
+
+	admin.DELETE("/cache/*", func(ctx echov4.Context) error {
+		key := ctx.Param("*")
+		if key == "" {
+			return invalidParameter(fmt.Errorf("key is required"))
+		}
+		return s.invalidateCacheKey(ctx, key)
+	})
+
+	admin.POST("/refresh/:key", func(ctx echov4.Context) error {
+		return s.forceRefresh(ctx, ctx.Param("key"))
+	})
+}
+
+func (s *Service) listCache(ctx echov4.Context, prefix string) error {
+	inspector, ok := s.Cache.(CacheInspector)
+	if !ok {
+		return ErrCacheNotInspectable
+	}
+
+	entries := inspector.Entries(prefix)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+	return ctx.JSON(http.StatusOK, entries)
+}
+
+// invalidateCacheKey deletes key together with its last-known-good copy, so a
+// bad value cannot be served again as stale, and its recorded chain state.
+func (s *Service) invalidateCacheKey(ctx echov4.Context, key string) error {
+	s.Echo.Logger.Infof("admin: invalidating %s", key)
+
+	s.Cache.Delete(key)
+	s.Cache.Delete(staleKeyPrefix + key)
+	s.Cache.Delete(metaKeyPrefix + key)
+
+	return ctx.JSON(http.StatusOK, InvalidateResponse{Deleted: []string{key}})
+}
+
+func (s *Service) invalidateCachePrefix(ctx echov4.Context, prefix string) error {
+	inspector, ok := s.Cache.(CacheInspector)
+	if !ok {
+		return ErrCacheNotInspectable
+	}
+
+	s.Echo.Logger.Infof("admin: invalidating prefix %s", prefix)
+
+	deleted := []string{}
+	for _, entry := range inspector.Entries(prefix) {
+		s.Cache.Delete(entry.Key)
+		s.Cache.Delete(staleKeyPrefix + entry.Key)
+		s.Cache.Delete(metaKeyPrefix + entry.Key)
+		deleted = append(deleted, entry.Key)
+	}
+	// last-known-good copies whose primary key has already expired
+	for _, entry := range inspector.Entries(staleKeyPrefix + prefix) {
+		s.Cache.Delete(entry.Key)
+		deleted = append(deleted, entry.Key)
+	}
+	sort.Strings(deleted)
+
+	return ctx.JSON(http.StatusOK, InvalidateResponse{Deleted: deleted})
+}
+
+// forceRefresh re-fetches a key the refresher knows how to rebuild, whether or
+// not it is scheduled, and waits for the result.
+func (s *Service) forceRefresh(ctx echov4.Context, key string) error {
+	refresh, ok := s.refreshFuncs()[key]
+	if !ok && strings.HasPrefix(key, "validatorList.") {
+		chain, err := s.Registry.Lookup(strings.TrimPrefix(key, "validatorList."))
+		if err != nil {
+			return err
+		}
+		refresh, ok = func() ([]byte, error) { return s.getValidatorList(key, chain, 0) }, true
+	}
+	if !ok {
+		return newAPIError(http.StatusNotFound, CodeNotFound, "no refresh available for "+key)
+	}
+
+	s.Echo.Logger.Infof("admin: refreshing %s", key)
+
+	start := time.Now()
+	data, _, err := s.fetchOnce(key, refresh)
+	if err != nil {
+		s.Echo.Logger.Errorf("admin: refresh of %s failed after %s - %v", key, time.Since(start), err)
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, RefreshResponse{Key: key, Size: len(data)})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	echov4 "github.com/labstack/echo/v4"
+)
+
+// APIError is the JSON body of every error response. Code is stable and meant
+// for clients to branch on; Message is for humans. Upstream names the service
+// that failed, and Retryable tells clients whether the same request may succeed
+// later.
+type APIError struct {
+	Code      string      `json:"code"`
+	Status    int         `json:"status"`
+	Message   string      `json:"message"`
+	Upstream  string      `json:"upstream,omitempty"`
+	Retryable bool        `json:"retryable"`
+	Details   interface{} `json:"details,omitempty"`
+
+	err error
+}
+
+func (e *APIError) Error() string {
+	if e.Upstream != "" {
+		return e.Upstream + ": " + e.Message
+	}
+	return e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+const (
+	CodeInvalidParameter        = "invalid_parameter"
+	CodeNotFound                = "not_found"
+	CodeUnknownChain            = "unknown_chain"
+	CodeHistoryDisabled         = "history_disabled"
+	CodeNotImplemented          = "not_implemented"
+	CodeUpstreamUnavailable     = "upstream_unavailable"
+	CodeUpstreamTimeout         = "upstream_timeout"
+	CodeUpstreamInvalidResponse = "upstream_invalid_response"
+	CodeMisconfigured           = "misconfigured"
+	CodeInternal                = "internal_error"
+	CodeRateLimited             = "rate_limited"
+)
+
+// apiErrors describes how each sentinel error is reported to clients. The
+// message is always the sentinel's own.
+var apiErrors = map[error]APIError{
+	ErrRPCClientConnection:      {Code: CodeUpstreamUnavailable, Status: http.StatusBadGateway, Retryable: true},
+	ErrABCIQuery:                {Code: CodeUpstreamUnavailable, Status: http.StatusBadGateway, Retryable: true},
+	ErrUnmarshalResponse:        {Code: CodeUpstreamInvalidResponse, Status: http.StatusBadGateway},
+	ErrMarshalResponse:          {Code: CodeInternal, Status: http.StatusInternalServerError},
+	ErrUnableToGetAPR:           {Code: CodeUpstreamUnavailable, Status: http.StatusBadGateway, Retryable: true},
+	ErrUnableToGetLockedTokens:  {Code: CodeUpstreamUnavailable, Status: http.StatusBadGateway, Retryable: true},
+	ErrUnableToGetTotalSupply:   {Code: CodeUpstreamUnavailable, Status: http.StatusBadGateway, Retryable: true},
+	ErrUnableToGetCommunityPool: {Code: CodeUpstreamUnavailable, Status: http.StatusBadGateway, Retryable: true},
+	ErrUnableToGetCommission:    {Code: CodeUpstreamUnavailable, Status: http.StatusBadGateway, Retryable: true},
+	ErrUnableToGetPrices:        {Code: CodeUpstreamUnavailable, Status: http.StatusBadGateway, Retryable: true},
+	ErrUnableToGetTopAccounts:   {Code: CodeUpstreamUnavailable, Status: http.StatusBadGateway, Retryable: true},
+	ErrUnableToGetSigningInfos:  {Code: CodeUpstreamUnavailable, Status: http.StatusBadGateway, Retryable: true},
+	ErrUnknownAPRProvider:       {Code: CodeMisconfigured, Status: http.StatusInternalServerError},
+	ErrUnknownPriceSource:       {Code: CodeMisconfigured, Status: http.StatusInternalServerError},
+	ErrHistoryDisabled:          {Code: CodeHistoryDisabled, Status: http.StatusNotFound},
+	ErrUnknownChain:             {Code: CodeUnknownChain, Status: http.StatusNotFound},
+	ErrCacheNotInspectable:      {Code: CodeNotImplemented, Status: http.StatusNotImplemented},
+	ErrRateLimited:              {Code: CodeRateLimited, Status: http.StatusTooManyRequests, Retryable: true},
+}
+
+// newAPIError builds an error response that does not stem from a sentinel.
+func newAPIError(status int, code string, message string) *APIError {
+	return &APIError{Code: code, Status: status, Message: message}
+}
+
+// invalidParameter reports a malformed request parameter as a 400.
+func invalidParameter(err error) *APIError {
+	return &APIError{Code: CodeInvalidParameter, Status: http.StatusBadRequest, Message: err.Error(), err: err}
+}
+
+// upstreamError reports sentinel as a failure talking to upstream, escalating
+// to a 504 when cause is a timeout. cause itself is only ever logged.
+func upstreamError(sentinel error, upstream string, cause error) *APIError {
+	apiErr := sentinelError(sentinel)
+	apiErr.Upstream = upstream
+	if isTimeout(cause) {
+		apiErr.Code = CodeUpstreamTimeout
+		apiErr.Status = http.StatusGatewayTimeout
+		apiErr.Retryable = true
+	}
+	return apiErr
+}
+
+// sentinelError returns the response for sentinel, or a 500 for errors that
+// have no entry in apiErrors.
+func sentinelError(sentinel error) *APIError {
+	apiErr, ok := apiErrors[sentinel]
+	if !ok {
+		apiErr = APIError{Code: CodeInternal, Status: http.StatusInternalServerError}
+	}
+	apiErr.Message = sentinel.Error()
+	apiErr.err = sentinel
+	return &apiErr
+}
+
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// toAPIError converts any error returned by a handler into its response.
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var httpErr *echov4.HTTPError
+	if errors.As(err, &httpErr) {
+		message, ok := httpErr.Message.(string)
+		if !ok {
+			message = http.StatusText(httpErr.Code)
+		}
+		return &APIError{
+			Code:    strings.ReplaceAll(strings.ToLower(http.StatusText(httpErr.Code)), " ", "_"),
+			Status:  httpErr.Code,
+			Message: message,
+			err:     httpErr,
+		}
+	}
+
+	for sentinel := range apiErrors {
+		if errors.Is(err, sentinel) {
+			return sentinelError(sentinel)
+		}
+	}
+
+	if isTimeout(err) {
+		return &APIError{Code: CodeUpstreamTimeout, Status: http.StatusGatewayTimeout, Message: "upstream timed out", Retryable: true, err: err}
+	}
+
+	return &APIError{Code: CodeInternal, Status: http.StatusInternalServerError, Message: http.StatusText(http.StatusInternalServerError), err: err}
+}
+
+// HTTPErrorHandler renders every error as an APIError. Server side failures
+// are logged with their underlying cause.
+func (s *Service) HTTPErrorHandler(err error, ctx echov4.Context) {
+	if ctx.Response().Committed {
+		return
+	}
+
+	apiErr := toAPIError(err)
+	if apiErr.Status >= http.StatusInternalServerError {
+		s.Echo.Logger.Errorf("%s %s: %v - %v", ctx.Request().Method, ctx.Request().URL.Path, apiErr.Code, err)
+	}
+
+	if ctx.Request().Method == http.MethodHead {
+		err = ctx.NoContent(apiErr.Status)
+	} else {
+		err = ctx.JSON(apiErr.Status, apiErr)
+	}
+	if err != nil {
+		s.Echo.Logger.Errorf("HTTPErrorHandler: %v", err)
+	}
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	sdkmath "cosmossdk.io/math"
-	"github.com/dgraph-io/ristretto"
+	"github.com/cosmos/cosmos-sdk/codec"
+	cdctypes "github.com/cosmos/cosmos-sdk/codec/types"
+	paramsproposal "github.com/cosmos/cosmos-sdk/x/params/types/proposal"
+	icstypes "github.com/ingenuity-build/quicksilver/x/interchainstaking/types"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
 )
 
 type Chain struct {
@@ -25,43 +31,220 @@ type APRResponse struct {
 	Chains []ChainAPR `json:"chains"`
 }
 
+// ChainAPR breaks down the staking return for a zone. APR is retained for
+// existing clients and always equals APY.
 type ChainAPR struct {
-	ChainID string  `json:"chain_id"`
-	APR     float64 `json:"apr"`
+	ChainID        string  `json:"chain_id"`
+	APR            float64 `json:"apr"`
+	RawAPR         float64 `json:"raw_apr"`
+	FeeAdjustedAPR float64 `json:"fee_adjusted_apr"`
+	APY            float64 `json:"apy"`
 }
 
-func getAPRquery(cache *ristretto.Cache, cfg Config, chainName string) (ChainAPR, error) {
-	var apr float64
-	var chainID string
-	var err error
+const (
+	defaultAPRFeeRate            = 0.035
+	defaultAPRCompoundingPeriods = 121.66
+)
 
-	client := &http.Client{Timeout: time.Duration(3) * time.Second}
+// fallbackFeeRate is the protocol fee used when the on-chain commission rate
+// cannot be queried.
+func fallbackFeeRate(cfg Config) float64 {
+	if cfg.APRFeeRate != nil {
+		return *cfg.APRFeeRate
+	}
+	return defaultAPRFeeRate
+}
 
-	switch chainName {
-	case "sommelier":
-		chainID, apr, err = SommelierApr(client, cfg, chainName)
-	case "stargaze":
-		chainID, apr, err = StargazeApr(client, cfg, chainName)
-	default:
-		chainID, apr, err = BasicApr(cache, client, cfg, chainName)
+// getCommissionRate queries the interchainstaking CommissionRate param from the
+// Quicksilver chain.
+func getCommissionRate(cache Cache, cfg Config) (float64, error) {
+	key := "ics.commission_rate"
+	cached, found := cache.Get(key)
+	if found {
+		return cached.(float64), nil
 	}
+
+	// establish client connection
+	client, err := NewRPCClient(cfg.RpcEndpoint, 10*time.Second)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrRPCClientConnection, err)
+	}
+
+	// prepare codecs
+	marshaler := codec.NewProtoCodec(cdctypes.NewInterfaceRegistry())
+
+	// prepare query
+	query := paramsproposal.QueryParamsRequest{
+		Subspace: icstypes.ModuleName,
+		Key:      string(icstypes.KeyCommissionRate),
+	}
+	qBytes := marshaler.MustMarshal(&query)
+
+	// execute query
+	abciquery, err := client.ABCIQueryWithOptions(
+		context.Background(),
+		"/cosmos.params.v1beta1.Query/Params",
+		qBytes,
+		rpcclient.ABCIQueryOptions{Height: 0},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrABCIQuery, err)
+	}
+	if abciquery.Response.Code != 0 {
+		return 0, fmt.Errorf("%w: %s", ErrABCIQuery, abciquery.Response.Log)
+	}
+
+	// decode query response
+	queryResponse := paramsproposal.QueryParamsResponse{}
+	if err := marshaler.Unmarshal(abciquery.Response.Value, &queryResponse); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnmarshalResponse, err)
+	}
+
+	var commissionRate sdkmath.LegacyDec
+	if err := json.Unmarshal([]byte(queryResponse.Param.Value), &commissionRate); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnmarshalResponse, err)
+	}
+	feeRate, err := commissionRate.Float64()
+	if err != nil {
+		return 0, err
+	}
+
+	cache.SetWithTTL(key, feeRate, 1*time.Hour)
+
+	return feeRate, nil
+}
+
+// feeExemptChains are staked natively rather than through Quicksilver, so
+// their APR carries no protocol fee and is not compounded.
+var feeExemptChains = map[string]bool{
+	"quicksilver": true,
+}
+
+// aprFeeModel returns the fee rate, compounding periods per year and whether
+// the provider already reports a compounded value for chainName. protocolFeeRate
+// applies unless the chain is fee exempt or overrides it.
+func aprFeeModel(cfg Config, chainName string, protocolFeeRate float64) (float64, float64, bool) {
+	pcfg := cfg.APRProviders[chainName]
+
+	feeRate := protocolFeeRate
+	periods := defaultAPRCompoundingPeriods
+	if cfg.APRCompounding != nil {
+		periods = *cfg.APRCompounding
+	}
+	if feeExemptChains[chainName] {
+		feeRate, periods = 0, 0
+	}
+
+	if pcfg.FeeRate != nil {
+		feeRate = *pcfg.FeeRate
+	}
+	if pcfg.CompoundingPeriods != nil {
+		periods = *pcfg.CompoundingPeriods
+	}
+
+	return feeRate, periods, pcfg.IsAPY
+}
+
+// netAPR applies the fee model to a raw provider value. A compounding period of
+// zero or less disables compounding.
+func netAPR(chainID string, apr float64, feeRate float64, periods float64, isAPY bool) ChainAPR {
+	feeAdjustedAPR := apr * (1 - feeRate)
+	apy := feeAdjustedAPR
+	if !isAPY && periods > 0 {
+		apy = math.Pow(1+feeAdjustedAPR/periods, periods) - 1
+	}
+	return ChainAPR{
+		ChainID:        chainID,
+		APR:            apy,
+		RawAPR:         apr,
+		FeeAdjustedAPR: feeAdjustedAPR,
+		APY:            apy,
+	}
+}
+
+// APRProvider returns the chain id and estimated staking APR for a chain.
+type APRProvider interface {
+	APR(client *http.Client, chainName string) (string, float64, error)
+}
+
+// APRProviderFactory builds an APRProvider from its per-chain configuration.
+type APRProviderFactory func(cache Cache, cfg Config, pcfg APRProviderConfig) APRProvider
+
+const defaultAPRProvider = "cosmos.directory"
+
+var aprProviders = map[string]APRProviderFactory{
+	"cosmos.directory": func(cache Cache, cfg Config, pcfg APRProviderConfig) APRProvider {
+		baseURL := pcfg.Endpoint
+		if baseURL == "" {
+			baseURL = cfg.APRURL
+		}
+		return &CosmosDirectoryAPR{cache: cache, baseURL: baseURL, chainID: pcfg.ChainID}
+	},
+	"mint": func(_ Cache, _ Config, pcfg APRProviderConfig) APRProvider {
+		return &MintAPR{
+			chainID:        pcfg.ChainID,
+			endpoint:       pcfg.Endpoint,
+			provisionsPath: pcfg.ProvisionsPath,
+			poolPath:       pcfg.PoolPath,
+		}
+	},
+	"incentives": func(_ Cache, _ Config, pcfg APRProviderConfig) APRProvider {
+		return &IncentivesAPR{chainID: pcfg.ChainID, url: pcfg.Endpoint, field: pcfg.Field}
+	},
+	"static": func(_ Cache, _ Config, pcfg APRProviderConfig) APRProvider {
+		return &StaticAPR{chainID: pcfg.ChainID, value: pcfg.Value}
+	},
+}
+
+// RegisterAPRProvider makes an APR provider available to the apr_providers config under name.
+func RegisterAPRProvider(name string, factory APRProviderFactory) {
+	aprProviders[name] = factory
+}
+
+// newAPRProvider resolves the provider configured for chainName, defaulting to cosmos.directory.
+func newAPRProvider(cache Cache, cfg Config, chainName string) (APRProvider, error) {
+	pcfg := cfg.APRProviders[chainName]
+	name := pcfg.Provider
+	if name == "" {
+		name = defaultAPRProvider
+	}
+
+	factory, ok := aprProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAPRProvider, name)
+	}
+	return factory(cache, cfg, pcfg), nil
+}
+
+func getAPRquery(cache Cache, cfg Config, chainName string, protocolFeeRate float64) (ChainAPR, error) {
+	client := &http.Client{Timeout: time.Duration(3) * time.Second}
+
+	provider, err := newAPRProvider(cache, cfg, chainName)
 	if err != nil {
 		return ChainAPR{}, err
 	}
 
-	if chainName != "quicksilver" {
-		feeadjustedAPR := (apr) * (0.965)
-		compoundedAPR := math.Pow(1+feeadjustedAPR/121.66, 121.66) - 1
-		return ChainAPR{ChainID: chainID, APR: compoundedAPR}, nil
+	chainID, apr, err := provider.APR(client, chainName)
+	if err != nil {
+		return ChainAPR{}, err
 	}
-	return ChainAPR{ChainID: chainID, APR: apr}, nil
+
+	feeRate, periods, isAPY := aprFeeModel(cfg, chainName, protocolFeeRate)
+	return netAPR(chainID, apr, feeRate, periods, isAPY), nil
 }
 
-func BasicApr(cache *ristretto.Cache, client *http.Client, cfg Config, chainName string) (string, float64, error) {
-	url := fmt.Sprintf("%s/%s", cfg.APRURL, chainName)
+// CosmosDirectoryAPR reads the estimated APR published by chains.cosmos.directory.
+type CosmosDirectoryAPR struct {
+	cache   Cache
+	baseURL string
+	chainID string
+}
+
+func (p *CosmosDirectoryAPR) APR(client *http.Client, chainName string) (string, float64, error) {
+	url := fmt.Sprintf("%s/%s", p.baseURL, chainName)
 	var result map[string]json.RawMessage
 
-	cachedResult, ok := cache.Get("aprbasic/" + chainName)
+	cachedResult, ok := p.cache.Get("aprbasic/" + chainName)
 
 	if !ok {
 		resp, err := client.Get(url)
@@ -75,7 +258,7 @@ func BasicApr(cache *ristretto.Cache, client *http.Client, cfg Config, chainName
 		if err != nil {
 			return "", 0, err
 		}
-		cache.SetWithTTL("aprbasic/"+chainName, result, 1, time.Duration(3)*time.Hour)
+		p.cache.SetWithTTL("aprbasic/"+chainName, result, time.Duration(3)*time.Hour)
 	} else {
 		result = cachedResult.(map[string]json.RawMessage)
 	}
@@ -85,14 +268,33 @@ func BasicApr(cache *ristretto.Cache, client *http.Client, cfg Config, chainName
 		return "", 0, err
 	}
 
+	if p.chainID != "" {
+		return p.chainID, chain.Params.EstimatedApr, nil
+	}
 	return chain.ChainID, chain.Params.EstimatedApr, nil
 }
 
-func StargazeApr(client *http.Client, cfg Config, chainname string) (string, float64, error) {
-	provisionsUrl := "https://stargaze-1.lcd.quicksilver.zone/stargaze/mint/v1beta1/annual_provisions"
-	bondedUrl := "https://stargaze-1.lcd.quicksilver.zone/cosmos/staking/v1beta1/pool"
+// MintAPR derives APR as annual provisions over bonded tokens, for chains whose
+// mint module does not follow the inflation model cosmos.directory assumes.
+type MintAPR struct {
+	chainID        string
+	endpoint       string
+	provisionsPath string
+	poolPath       string
+}
 
-	provisionQuery, err := client.Get(provisionsUrl)
+func (p *MintAPR) APR(client *http.Client, chainName string) (string, float64, error) {
+	provisionsPath := p.provisionsPath
+	if provisionsPath == "" {
+		provisionsPath = "/cosmos/mint/v1beta1/annual_provisions"
+	}
+	poolPath := p.poolPath
+	if poolPath == "" {
+		poolPath = "/cosmos/staking/v1beta1/pool"
+	}
+	endpoint := strings.TrimSuffix(p.endpoint, "/")
+
+	provisionQuery, err := client.Get(endpoint + provisionsPath)
 	if err != nil {
 		return "", 0, err
 	}
@@ -102,7 +304,6 @@ func StargazeApr(client *http.Client, cfg Config, chainname string) (string, flo
 	var provisionsResult map[string]sdkmath.LegacyDec
 	provisionsResponse, err := io.ReadAll(provisionQuery.Body)
 	if err != nil {
-		fmt.Println("Error:", err)
 		return "", 0, err
 	}
 	err = json.Unmarshal(provisionsResponse, &provisionsResult)
@@ -110,12 +311,12 @@ func StargazeApr(client *http.Client, cfg Config, chainname string) (string, flo
 		return "", 0, err
 	}
 
-	provisions, err := provisionsResult["annual_provisions"].Float64()
-	if err != nil {
-		return "", 0, err
+	annualProvisions, ok := provisionsResult["annual_provisions"]
+	if !ok || annualProvisions.IsNil() {
+		return "", 0, fmt.Errorf("no annual provisions reported for %s", chainName)
 	}
 
-	bondedQuery, err := client.Get(bondedUrl)
+	bondedQuery, err := client.Get(endpoint + poolPath)
 	if err != nil {
 		return "", 0, err
 	}
@@ -125,7 +326,6 @@ func StargazeApr(client *http.Client, cfg Config, chainname string) (string, flo
 	var bondedResult map[string]map[string]sdkmath.Int
 	bondedResponse, err := io.ReadAll(bondedQuery.Body)
 	if err != nil {
-		fmt.Println("Error:", err)
 		return "", 0, err
 	}
 	err = json.Unmarshal(bondedResponse, &bondedResult)
@@ -133,21 +333,41 @@ func StargazeApr(client *http.Client, cfg Config, chainname string) (string, flo
 		return "", 0, err
 	}
 
-	bonded := float64(bondedResult["pool"]["bonded_tokens"].Int64())
+	bonded := bondedResult["pool"]["bonded_tokens"]
+	if bonded.IsNil() || bonded.IsZero() {
+		return "", 0, fmt.Errorf("no bonded tokens reported for %s", chainName)
+	}
+
+	// divide in decimals; bonded supplies of 18 decimal chains overflow an int64
+	apr, err := annualProvisions.Quo(sdkmath.LegacyNewDecFromInt(bonded)).Float64()
+	if err != nil {
+		return "", 0, err
+	}
+	return p.chainID, apr, nil
+}
 
-	return "stargaze-1", provisions / bonded, nil
+// IncentivesAPR reads a precomputed APY from a single decimal field of a custom endpoint.
+type IncentivesAPR struct {
+	chainID string
+	url     string
+	field   string
 }
 
-func SommelierApr(client *http.Client, cfg Config, chainname string) (string, float64, error) {
-	url := "https://sommelier-3.lcd.quicksilver.zone/sommelier/incentives/v1/apy"
-	query, err := client.Get(url)
+func (p *IncentivesAPR) APR(client *http.Client, chainName string) (string, float64, error) {
+	field := p.field
+	if field == "" {
+		field = "apy"
+	}
+
+	query, err := client.Get(p.url)
 	if err != nil {
 		return "", 0, err
 	}
+	defer query.Body.Close()
+
 	var result map[string]sdkmath.LegacyDec
 	response, err := io.ReadAll(query.Body)
 	if err != nil {
-		fmt.Println("Error:", err)
 		return "", 0, err
 	}
 	err = json.Unmarshal(response, &result)
@@ -155,9 +375,23 @@ func SommelierApr(client *http.Client, cfg Config, chainname string) (string, fl
 		return "", 0, err
 	}
 
-	apy, err := result["apy"].Float64()
+	value, ok := result[field]
+	if !ok || value.IsNil() {
+		return "", 0, fmt.Errorf("field %q missing from %s response", field, chainName)
+	}
+	apy, err := value.Float64()
 	if err != nil {
 		return "", 0, err
 	}
-	return "sommelier-3", apy, nil
+	return p.chainID, apy, nil
+}
+
+// StaticAPR returns a fixed APR from config.
+type StaticAPR struct {
+	chainID string
+	value   float64
+}
+
+func (p *StaticAPR) APR(_ *http.Client, _ string) (string, float64, error) {
+	return p.chainID, p.value, nil
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	echov4 "github.com/labstack/echo/v4"
+)
+
+type APRHistoryPoint struct {
+	Time           time.Time `json:"time"`
+	APR            float64   `json:"apr"`
+	RawAPR         float64   `json:"raw_apr"`
+	FeeAdjustedAPR float64   `json:"fee_adjusted_apr"`
+	APY            float64   `json:"apy"`
+	Samples        int       `json:"samples"`
+}
+
+type APRHistoryResponse struct {
+	ChainID string            `json:"chain_id"`
+	Points  []APRHistoryPoint `json:"points"`
+}
+
+func aprHistorySeries(chainID string) string {
+	return "apr/" + chainID
+}
+
+// sampleAPR records the APR of every configured chain into the history store
+// each interval until ctx is cancelled.
+func (s *Service) sampleAPR(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.recordAPR(time.Now().UTC())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) recordAPR(now time.Time) {
+	s.Echo.Logger.Infof("recordAPR")
+
+	// share the cached apr with the /apr endpoint rather than querying again
+	data, err := s.cachedData("apr", func() ([]byte, error) { return s.getAPR("apr") })
+	if err != nil {
+		s.Echo.Logger.Errorf("recordAPR: %v", err)
+		return
+	}
+	var aprResp APRResponse
+	if err := json.Unmarshal(data, &aprResp); err != nil {
+		s.Echo.Logger.Errorf("recordAPR: %v - %v", ErrUnmarshalResponse, err)
+		return
+	}
+
+	for _, chainAPR := range aprResp.Chains {
+		// chains that failed to resolve carry no chain id
+		if chainAPR.ChainID == "" {
+			continue
+		}
+
+		value, err := json.Marshal(chainAPR)
+		if err != nil {
+			s.Echo.Logger.Errorf("recordAPR: %v - %v", ErrMarshalResponse, err)
+			continue
+		}
+		if err := s.History.Record(aprHistorySeries(chainAPR.ChainID), now, value); err != nil {
+			s.Echo.Logger.Errorf("recordAPR: unable to record apr for %s - %v", chainAPR.ChainID, err)
+		}
+	}
+}
+
+func (s *Service) getAPRHistory(ctx echov4.Context, chainId string) error {
+	points := []APRHistoryPoint{}
+	err := s.rangeHistory(ctx, aprHistorySeries(chainId), func(bucket time.Time, value []byte) error {
+		var chainAPR ChainAPR
+		if err := json.Unmarshal(value, &chainAPR); err != nil {
+			return err
+		}
+
+		// average samples that fall into the same interval bucket
+		if n := len(points); n > 0 && points[n-1].Time.Equal(bucket) {
+			p := &points[n-1]
+			p.Samples++
+			weight := float64(p.Samples)
+			p.APR += (chainAPR.APR - p.APR) / weight
+			p.RawAPR += (chainAPR.RawAPR - p.RawAPR) / weight
+			p.FeeAdjustedAPR += (chainAPR.FeeAdjustedAPR - p.FeeAdjustedAPR) / weight
+			p.APY += (chainAPR.APY - p.APY) / weight
+			return nil
+		}
+
+		points = append(points, APRHistoryPoint{
+			Time:           bucket,
+			APR:            chainAPR.APR,
+			RawAPR:         chainAPR.RawAPR,
+			FeeAdjustedAPR: chainAPR.FeeAdjustedAPR,
+			APY:            chainAPR.APY,
+			Samples:        1,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if ctx.QueryParam("format") == "csv" {
+		rows := make([][]string, 0, len(points))
+		for _, p := range points {
+			rows = append(rows, historyCSVRow(p.Time, p.Samples, p.APR, p.RawAPR, p.FeeAdjustedAPR, p.APY))
+		}
+		return writeHistoryCSV(ctx, "apr-"+chainId+".csv", []string{"time", "apr", "raw_apr", "fee_adjusted_apr", "apy", "samples"}, rows)
+	}
+
+	return ctx.JSON(http.StatusOK, APRHistoryResponse{ChainID: chainId, Points: points})
+}
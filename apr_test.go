@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMintAPR(t *testing.T) {
+	tests := []struct {
+		name       string
+		provisions string
+		bonded     string
+		want       float64
+		wantErr    bool
+	}{
+		{name: "6 decimals", provisions: "15000000000.000000000000000000", bonded: "100000000000", want: 0.15},
+		// 2^63 is 9223372036854775808; an 18 decimal chain bonds far more base units
+		{name: "bonded above 2^63", provisions: "24000000000000000000000000.000000000000000000", bonded: "200000000000000000000000000", want: 0.12},
+		{name: "no bonded tokens", provisions: "1.0", bonded: "0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/cosmos/mint/v1beta1/annual_provisions", func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(`{"annual_provisions":"` + tt.provisions + `"}`))
+			})
+			mux.HandleFunc("/cosmos/staking/v1beta1/pool", func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(`{"pool":{"not_bonded_tokens":"0","bonded_tokens":"` + tt.bonded + `"}}`))
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			provider := &MintAPR{chainID: "dydx-mainnet-1", endpoint: server.URL + "/"}
+			chainID, apr, err := provider.APR(server.Client(), "dydx")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("APR() = %v, want an error", apr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("APR(): %v", err)
+			}
+			if chainID != "dydx-mainnet-1" {
+				t.Errorf("chain id = %q, want dydx-mainnet-1", chainID)
+			}
+			if math.Abs(apr-tt.want) > 1e-12 {
+				t.Errorf("APR() = %v, want %v", apr, tt.want)
+			}
+		})
+	}
+}
+
+func TestCosmosDirectoryEndpoint(t *testing.T) {
+	cfg := Config{
+		APRURL: "https://chains.cosmos.directory",
+		APRProviders: map[string]APRProviderConfig{
+			"osmosis": {Endpoint: "https://chains.example.com"},
+		},
+	}
+
+	for chainName, want := range map[string]string{
+		"osmosis":   "https://chains.example.com",
+		"cosmoshub": "https://chains.cosmos.directory",
+		"stargaze":  "https://chains.cosmos.directory",
+	} {
+		provider, err := newAPRProvider(nil, cfg, chainName)
+		if err != nil {
+			t.Fatalf("newAPRProvider(%q): %v", chainName, err)
+		}
+		directory, ok := provider.(*CosmosDirectoryAPR)
+		if !ok {
+			t.Fatalf("newAPRProvider(%q) = %T, want *CosmosDirectoryAPR", chainName, provider)
+		}
+		if directory.baseURL != want {
+			t.Errorf("%s base url = %q, want %q", chainName, directory.baseURL, want)
+		}
+	}
+}
+
+func TestAPRFeeModel(t *testing.T) {
+	zero, half, daily := 0.0, 0.5, 365.0
+	tests := []struct {
+		name        string
+		providers   map[string]APRProviderConfig
+		chainName   string
+		wantFee     float64
+		wantPeriods float64
+	}{
+		{name: "protocol fee", chainName: "cosmoshub", wantFee: 0.1, wantPeriods: defaultAPRCompoundingPeriods},
+		{name: "quicksilver is exempt by default", chainName: "quicksilver", wantFee: 0, wantPeriods: 0},
+		{
+			name:        "config overrides the exemption",
+			providers:   map[string]APRProviderConfig{"quicksilver": {FeeRate: &half, CompoundingPeriods: &daily}},
+			chainName:   "quicksilver",
+			wantFee:     0.5,
+			wantPeriods: 365,
+		},
+		{
+			name:        "config overrides the protocol fee",
+			providers:   map[string]APRProviderConfig{"osmosis": {FeeRate: &zero}},
+			chainName:   "osmosis",
+			wantFee:     0,
+			wantPeriods: defaultAPRCompoundingPeriods,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feeRate, periods, _ := aprFeeModel(Config{APRProviders: tt.providers}, tt.chainName, 0.1)
+			if feeRate != tt.wantFee || periods != tt.wantPeriods {
+				t.Errorf("aprFeeModel(%s) = %v, %v, want %v, %v", tt.chainName, feeRate, periods, tt.wantFee, tt.wantPeriods)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	echov4 "github.com/labstack/echo/v4"
+)
+
+// Cache is the store behind every cached endpoint. Values are []byte responses
+// or the structured intermediate results registered in snapshot.go.
+type Cache interface {
+	Get(key string) (interface{}, bool)
+	SetWithTTL(key string, value interface{}, ttl time.Duration) bool
+	Delete(key string)
+}
+
+// CacheConfig selects the cache backend. ristretto, the default, keeps the cache
+// in process, bounded to MaxCostBytes of values; redis shares it between replicas.
+type CacheConfig struct {
+	Backend      string      `yaml:"backend" json:"backend"`
+	MaxCostBytes int64       `yaml:"max_cost_bytes" json:"max_cost_bytes"`
+	NumCounters  int64       `yaml:"num_counters" json:"num_counters"`
+	Redis        RedisConfig `yaml:"redis" json:"redis"`
+}
+
+const (
+	staleKeyPrefix = "stale."
+
+	defaultStaleMaxAge = 24 * time.Hour
+)
+
+// StaleConfig controls how long a last-known-good response outlives its TTL.
+// Within GraceSeconds of expiry the stale copy is served immediately while the
+// key is refreshed in the background; up to MaxAgeMinutes it is served only
+// when the upstream fetch fails.
+type StaleConfig struct {
+	GraceSeconds  int `yaml:"grace_seconds" json:"grace_seconds"`
+	MaxAgeMinutes int `yaml:"max_age_minutes" json:"max_age_minutes"`
+}
+
+// valueSize approximates the memory held by a cached value in bytes. It is the
+// cost the value is charged against the cache budget.
+func valueSize(value interface{}) int {
+	switch v := value.(type) {
+	case []byte:
+		return len(v)
+	case cachedResponse:
+		return len(v.Data)
+	default:
+		data, err := encodeCacheValue(v)
+		if err != nil {
+			return 0
+		}
+		return len(data)
+	}
+}
+
+// cachedResponse is the last-known-good copy of a cached endpoint response,
+// and the chain state it was read from when known.
+type cachedResponse struct {
+	ChainMeta
+	Data      []byte
+	FetchedAt time.Time
+	ExpiresAt time.Time
+}
+
+func (s *Service) staleMaxAge() time.Duration {
+	if s.Config.Stale.MaxAgeMinutes > 0 {
+		return time.Duration(s.Config.Stale.MaxAgeMinutes) * time.Minute
+	}
+	return defaultStaleMaxAge
+}
+
+// setCached caches an endpoint response under key for ttl and keeps a
+// last-known-good copy that outlives it by the configured stale max age.
+func (s *Service) setCached(key string, data []byte, ttl time.Duration) {
+	s.setCachedAt(key, data, ttl, ChainMeta{})
+}
+
+// setCachedAt is setCached for a response read from the chain state meta.
+func (s *Service) setCachedAt(key string, data []byte, ttl time.Duration, meta ChainMeta) {
+	now := time.Now()
+	s.Cache.SetWithTTL(key, data, ttl)
+	s.Cache.SetWithTTL(staleKeyPrefix+key, cachedResponse{
+		ChainMeta: meta,
+		Data:      data,
+		FetchedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, ttl+s.staleMaxAge())
+}
+
+// serveCached responds with the value cached under key. Keys kept warm by the
+// refresher are normally always present; on a miss the value is fetched inline.
+func (s *Service) serveCached(ctx echov4.Context, key string, fetch func() ([]byte, error)) error {
+	data, err := s.cached(ctx, key, fetch)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSONBlob(http.StatusOK, data)
+}
+
+// cached returns the value cached under key, falling back to the last-known-good
+// copy while it is within the grace window or when fetch fails. Stale responses
+// are marked with Age and X-Evince-Stale headers.
+func (s *Service) cached(ctx echov4.Context, key string, fetch func() ([]byte, error)) ([]byte, error) {
+	data, found := s.Cache.Get(key)
+	if found {
+		cacheLookups.WithLabelValues(metricKey(key), "hit").Inc()
+		return data.([]byte), nil
+	}
+
+	var stale *cachedResponse
+	if value, found := s.Cache.Get(staleKeyPrefix + key); found {
+		entry := value.(cachedResponse)
+		stale = &entry
+	}
+
+	grace := time.Duration(s.Config.Stale.GraceSeconds) * time.Second
+	if stale != nil && time.Since(stale.ExpiresAt) <= grace {
+		s.revalidate(key, fetch)
+		cacheLookups.WithLabelValues(metricKey(key), "stale").Inc()
+		markStale(ctx, stale, "revalidating")
+		return stale.Data, nil
+	}
+
+	respdata, coalesced, err := s.fetchOnce(key, fetch)
+	if coalesced {
+		cacheLookups.WithLabelValues(metricKey(key), "coalesced").Inc()
+	} else {
+		cacheLookups.WithLabelValues(metricKey(key), "miss").Inc()
+	}
+	if err != nil {
+		if stale != nil {
+			s.Echo.Logger.Warnf("serving stale %s after refresh failure - %v", key, err)
+			cacheLookups.WithLabelValues(metricKey(key), "stale").Inc()
+			markStale(ctx, stale, "upstream-error")
+			return stale.Data, nil
+		}
+		return nil, err
+	}
+
+	return respdata, nil
+}
+
+// cachedData is cached for internal callers that have no request to mark
+// stale: it returns the value cached under key, fetching it on a miss and
+// falling back to the last-known-good copy when the fetch fails.
+func (s *Service) cachedData(key string, fetch func() ([]byte, error)) ([]byte, error) {
+	if data, found := s.Cache.Get(key); found {
+		return data.([]byte), nil
+	}
+
+	respdata, _, err := s.fetchOnce(key, fetch)
+	if err != nil {
+		if value, found := s.Cache.Get(staleKeyPrefix + key); found {
+			s.Echo.Logger.Warnf("using stale %s after refresh failure - %v", key, err)
+			return value.(cachedResponse).Data, nil
+		}
+		return nil, err
+	}
+	return respdata, nil
+}
+
+// fetchOnce runs fetch for key, coalescing concurrent callers so that only one
+// upstream request is in flight per key and every waiter shares its result.
+// coalesced reports whether this caller waited on a fetch started by another.
+func (s *Service) fetchOnce(key string, fetch func() ([]byte, error)) (data []byte, coalesced bool, err error) {
+	executed := false
+	result, err, _ := s.inflight.Do(key, func() (interface{}, error) {
+		executed = true
+		return fetch()
+	})
+	if err != nil {
+		return nil, !executed, err
+	}
+	return result.([]byte), !executed, nil
+}
+
+// revalidate refreshes key in the background unless a refresh is already running.
+func (s *Service) revalidate(key string, fetch func() ([]byte, error)) {
+	if _, running := s.revalidating.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer s.revalidating.Delete(key)
+		if _, _, err := s.fetchOnce(key, fetch); err != nil {
+			s.Echo.Logger.Errorf("revalidate: %s failed - %v", key, err)
+		}
+	}()
+}
+
+func markStale(ctx echov4.Context, entry *cachedResponse, reason string) {
+	age := int(time.Since(entry.FetchedAt).Seconds())
+	ctx.Response().Header().Set("Age", strconv.Itoa(age))
+	ctx.Response().Header().Set("X-Evince-Stale", reason)
+}
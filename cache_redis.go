@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisConfig configures the shared Redis cache backend. REDIS_PASSWORD
+// overrides Password when set.
+type RedisConfig struct {
+	Addr      string `yaml:"addr" json:"addr"`
+	Password  string `yaml:"password" json:"-"`
+	DB        int    `yaml:"db" json:"db"`
+	KeyPrefix string `yaml:"key_prefix" json:"key_prefix"`
+}
+
+const redisTimeout = 2 * time.Second
+
+// RedisCache is a Cache shared between replicas. Values are gob encoded with
+// encodeCacheValue; Redis errors are treated as cache misses.
+type RedisCache struct {
+	client *redis.Client
+	prefix string
+}
+
+var _ Cache = (*RedisCache)(nil)
+
+func NewRedisCache(cfg RedisConfig) (*RedisCache, error) {
+	password := cfg.Password
+	if env := os.Getenv("REDIS_PASSWORD"); env != "" {
+		password = env
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: password,
+		DB:       cfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisCache{client: client, prefix: cfg.KeyPrefix}, nil
+}
+
+func (c *RedisCache) Get(key string) (interface{}, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	value, err := decodeCacheValue(data)
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// SetWithTTL stores value under key. Redis enforces its own memory policy. A
+// ttl of zero never expires.
+func (c *RedisCache) SetWithTTL(key string, value interface{}, ttl time.Duration) bool {
+	data, err := encodeCacheValue(value)
+	if err != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return c.client.Set(ctx, c.prefix+key, data, ttl).Err() == nil
+}
+
+func (c *RedisCache) Delete(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	c.client.Del(ctx, c.prefix+key)
+}
+
+var _ CacheInspector = (*RedisCache)(nil)
+
+// Entries scans the keyspace under the configured key prefix. Size is the
+// encoded size stored in Redis.
+func (c *RedisCache) Entries(prefix string) []CacheEntry {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*redisTimeout)
+	defer cancel()
+
+	entries := []CacheEntry{}
+	iter := c.client.Scan(ctx, 0, redisGlobEscaper.Replace(c.prefix+prefix)+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+
+		pipe := c.client.Pipeline()
+		ttl := pipe.PTTL(ctx, key)
+		size := pipe.StrLen(ctx, key)
+		if _, err := pipe.Exec(ctx); err != nil {
+			continue
+		}
+
+		ttlSeconds := int64(-1)
+		if ttl.Val() > 0 {
+			ttlSeconds = int64(ttl.Val().Seconds())
+		}
+		entries = append(entries, CacheEntry{
+			Key:        strings.TrimPrefix(key, c.prefix),
+			TTLSeconds: ttlSeconds,
+			Size:       int(size.Val()),
+		})
+	}
+	return entries
+}
+
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisCache(t *testing.T, prefix string) (*RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	cache, err := NewRedisCache(RedisConfig{Addr: server.Addr(), KeyPrefix: prefix})
+	if err != nil {
+		t.Fatalf("NewRedisCache: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache, server
+}
+
+func TestRedisCacheRoundTrip(t *testing.T) {
+	cache, server := newTestRedisCache(t, "evince:")
+
+	fetchedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entry := cachedResponse{
+		ChainMeta: ChainMeta{ChainID: "quicksilver-2", Height: 1234, BlockTime: fetchedAt.Add(-5 * time.Second)},
+		Data:      []byte(`{"zones":[]}`),
+		FetchedAt: fetchedAt,
+		ExpiresAt: fetchedAt.Add(time.Minute),
+	}
+
+	if !cache.SetWithTTL("zones", []byte(`{"zones":[]}`), time.Minute) {
+		t.Fatal("SetWithTTL zones failed")
+	}
+	if !cache.SetWithTTL(staleKeyPrefix+"zones", entry, 2*time.Minute) {
+		t.Fatal("SetWithTTL stale.zones failed")
+	}
+
+	// keys are stored under the configured prefix
+	if !server.Exists("evince:zones") || !server.Exists("evince:stale.zones") {
+		t.Fatalf("expected prefixed keys, got %v", server.Keys())
+	}
+
+	value, found := cache.Get("zones")
+	if !found {
+		t.Fatal("zones not found")
+	}
+	data, ok := value.([]byte)
+	if !ok || !bytes.Equal(data, []byte(`{"zones":[]}`)) {
+		t.Fatalf("zones = %#v, want the cached bytes", value)
+	}
+
+	value, found = cache.Get(staleKeyPrefix + "zones")
+	if !found {
+		t.Fatal("stale.zones not found")
+	}
+	got, ok := value.(cachedResponse)
+	if !ok {
+		t.Fatalf("stale.zones = %T, want cachedResponse", value)
+	}
+	if got.ChainID != entry.ChainID || got.Height != entry.Height || !got.BlockTime.Equal(entry.BlockTime) ||
+		!bytes.Equal(got.Data, entry.Data) || !got.FetchedAt.Equal(entry.FetchedAt) || !got.ExpiresAt.Equal(entry.ExpiresAt) {
+		t.Fatalf("stale.zones = %+v, want %+v", got, entry)
+	}
+
+	cache.Delete("zones")
+	if _, found := cache.Get("zones"); found {
+		t.Fatal("zones found after Delete")
+	}
+	if _, found := cache.Get(staleKeyPrefix + "zones"); !found {
+		t.Fatal("Delete removed an unrelated key")
+	}
+}
+
+func TestRedisCacheTTL(t *testing.T) {
+	cache, server := newTestRedisCache(t, "")
+
+	cache.SetWithTTL("short", []byte("a"), time.Minute)
+	cache.SetWithTTL("long", []byte("b"), time.Hour)
+	cache.SetWithTTL("forever", []byte("c"), 0)
+
+	server.FastForward(2 * time.Minute)
+
+	for key, want := range map[string]bool{"short": false, "long": true, "forever": true} {
+		if _, found := cache.Get(key); found != want {
+			t.Errorf("Get(%q) found = %v, want %v", key, found, want)
+		}
+	}
+
+	server.FastForward(2 * time.Hour)
+
+	if _, found := cache.Get("long"); found {
+		t.Error("long found after its ttl")
+	}
+	if _, found := cache.Get("forever"); !found {
+		t.Error("forever expired")
+	}
+}
+
+func TestRedisCacheGetUndecodable(t *testing.T) {
+	cache, server := newTestRedisCache(t, "")
+
+	server.Set("garbage", "not gob")
+	if _, found := cache.Get("garbage"); found {
+		t.Error("undecodable value reported as found")
+	}
+}
+
+func TestRedisCacheEntries(t *testing.T) {
+	cache, _ := newTestRedisCache(t, "evince:")
+
+	cache.SetWithTTL("aprbasic/cosmoshub-4", []byte("12"), time.Hour)
+	cache.SetWithTTL("aprbasic/osmosis-1", []byte("1234"), 0)
+	cache.SetWithTTL("apr", []byte("1"), time.Hour)
+	cache.SetWithTTL("zones", []byte("1"), time.Hour)
+	cache.SetWithTTL("glob*", []byte("1"), time.Hour)
+	cache.SetWithTTL("globber", []byte("1"), time.Hour)
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"aprbasic/", []string{"aprbasic/cosmoshub-4", "aprbasic/osmosis-1"}},
+		{"apr", []string{"apr", "aprbasic/cosmoshub-4", "aprbasic/osmosis-1"}},
+		{"glob*", []string{"glob*"}},
+		{"missing", []string{}},
+		{"", []string{"apr", "aprbasic/cosmoshub-4", "aprbasic/osmosis-1", "glob*", "globber", "zones"}},
+	}
+	for _, tt := range tests {
+		keys := []string{}
+		for _, entry := range cache.Entries(tt.prefix) {
+			keys = append(keys, entry.Key)
+		}
+		sort.Strings(keys)
+		if !reflect.DeepEqual(keys, tt.want) {
+			t.Errorf("Entries(%q) = %v, want %v", tt.prefix, keys, tt.want)
+		}
+	}
+
+	entries := map[string]CacheEntry{}
+	for _, entry := range cache.Entries("aprbasic/") {
+		entries[entry.Key] = entry
+	}
+	if ttl := entries["aprbasic/cosmoshub-4"].TTLSeconds; ttl <= 0 || ttl > 3600 {
+		t.Errorf("aprbasic/cosmoshub-4 ttl = %d, want within an hour", ttl)
+	}
+	if ttl := entries["aprbasic/osmosis-1"].TTLSeconds; ttl != -1 {
+		t.Errorf("aprbasic/osmosis-1 ttl = %d, want -1 for no expiry", ttl)
+	}
+	if size := entries["aprbasic/osmosis-1"].Size; size <= 0 {
+		t.Errorf("aprbasic/osmosis-1 size = %d, want the encoded size", size)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/dgraph-io/ristretto/z"
+)
+
+// RistrettoCache is the default in-process Cache. It wraps ristretto with an
+// index of the keys that have been set and when they expire, which ristretto
+// itself cannot enumerate.
+type RistrettoCache struct {
+	*ristretto.Cache
+
+	// ristretto reports evictions by key hash, so the index is keyed the same
+	mu      sync.Mutex
+	keyHash func(key interface{}) (uint64, uint64)
+	index   map[uint64]ristrettoEntry
+}
+
+type ristrettoEntry struct {
+	key       string
+	conflict  uint64
+	size      int
+	expiresAt time.Time
+}
+
+// NewRistrettoCache creates a ristretto cache from config. Keys leave the
+// index when ristretto evicts, expires or rejects them.
+func NewRistrettoCache(config *ristretto.Config) (*RistrettoCache, error) {
+	c := &RistrettoCache{
+		keyHash: config.KeyToHash,
+		index:   map[uint64]ristrettoEntry{},
+	}
+	if c.keyHash == nil {
+		c.keyHash = z.KeyToHash
+	}
+
+	onEvict, onReject := config.OnEvict, config.OnReject
+	config.OnEvict = func(item *ristretto.Item) {
+		c.unindex(item)
+		if onEvict != nil {
+			onEvict(item)
+		}
+	}
+	config.OnReject = func(item *ristretto.Item) {
+		c.unindexRejected(item)
+		if onReject != nil {
+			onReject(item)
+		}
+	}
+
+	cache, err := ristretto.NewCache(config)
+	if err != nil {
+		return nil, err
+	}
+	c.Cache = cache
+	return c, nil
+}
+
+var _ Cache = (*RistrettoCache)(nil)
+
+func (c *RistrettoCache) Get(key string) (interface{}, bool) {
+	return c.Cache.Get(key)
+}
+
+// SetWithTTL stores value under key at a cost of its size in bytes. A ttl of
+// zero never expires. Ristretto applies sets of new keys asynchronously; call
+// Wait before reading one back.
+func (c *RistrettoCache) SetWithTTL(key string, value interface{}, ttl time.Duration) bool {
+	size := valueSize(value)
+	cost := int64(size)
+	if cost < 1 {
+		cost = 1
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	// index before setting; a rejection may be reported before Set returns
+	hash, conflict := c.keyHash(key)
+	c.mu.Lock()
+	c.index[hash] = ristrettoEntry{key: key, conflict: conflict, size: size, expiresAt: expiresAt}
+	c.mu.Unlock()
+
+	if !c.Cache.SetWithTTL(key, value, cost, ttl) {
+		c.mu.Lock()
+		delete(c.index, hash)
+		c.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+func (c *RistrettoCache) Delete(key string) {
+	c.Cache.Del(key)
+
+	hash, _ := c.keyHash(key)
+	c.mu.Lock()
+	delete(c.index, hash)
+	c.mu.Unlock()
+}
+
+// unindex drops an evicted, expired or rejected item from the index.
+func (c *RistrettoCache) unindex(item *ristretto.Item) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.index[item.Key]; ok && entry.conflict == item.Conflict {
+		delete(c.index, item.Key)
+	}
+}
+
+// unindexRejected drops a rejected item from the index. Ristretto also rejects
+// a second set of a new key that lands before the first is applied, so the
+// key is kept while it is still cached.
+func (c *RistrettoCache) unindexRejected(item *ristretto.Item) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.index[item.Key]
+	if !ok || entry.conflict != item.Conflict {
+		return
+	}
+	if _, live := c.Cache.GetTTL(entry.key); !live {
+		delete(c.index, item.Key)
+	}
+}
+
+// Keys returns every indexed key that has not expired, with its expiry.
+func (c *RistrettoCache) Keys() map[string]time.Time {
+	live := map[string]time.Time{}
+	for _, entry := range c.entries() {
+		live[entry.key] = entry.expiresAt
+	}
+	return live
+}
+
+// entries lists the live index entries. Liveness is checked with GetTTL, which
+// unlike Get does not count towards ristretto's hit and miss metrics.
+func (c *RistrettoCache) entries() []ristrettoEntry {
+	now := time.Now()
+
+	c.mu.Lock()
+	indexed := make([]ristrettoEntry, 0, len(c.index))
+	for _, entry := range c.index {
+		indexed = append(indexed, entry)
+	}
+	c.mu.Unlock()
+
+	live := indexed[:0]
+	for _, entry := range indexed {
+		if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+			continue
+		}
+		if _, found := c.Cache.GetTTL(entry.key); !found {
+			continue
+		}
+		live = append(live, entry)
+	}
+	return live
+}
+
+var _ CacheInspector = (*RistrettoCache)(nil)
+
+func (c *RistrettoCache) Entries(prefix string) []CacheEntry {
+	now := time.Now()
+	entries := []CacheEntry{}
+	for _, entry := range c.entries() {
+		if !strings.HasPrefix(entry.key, prefix) {
+			continue
+		}
+
+		ttl := int64(-1)
+		if !entry.expiresAt.IsZero() {
+			ttl = int64(entry.expiresAt.Sub(now).Seconds())
+		}
+		entries = append(entries, CacheEntry{Key: entry.key, TTLSeconds: ttl, Size: entry.size})
+	}
+	return entries
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+)
+
+func TestRistrettoCacheIndexPrunedOnEviction(t *testing.T) {
+	cache, err := NewRistrettoCache(&ristretto.Config{NumCounters: 1e3, MaxCost: 1 << 10, BufferItems: 64, IgnoreInternalCost: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	// each value costs a quarter of the cache, so most are evicted or rejected
+	for i := 0; i < 50; i++ {
+		cache.SetWithTTL(fmt.Sprintf("key%d", i), bytes.Repeat([]byte{'x'}, 256), time.Hour)
+	}
+	cache.Wait()
+
+	cache.mu.Lock()
+	indexed := make([]string, 0, len(cache.index))
+	for _, entry := range cache.index {
+		indexed = append(indexed, entry.key)
+	}
+	cache.mu.Unlock()
+
+	if len(indexed) > 4 {
+		t.Errorf("index holds %d keys, want at most the 4 that fit", len(indexed))
+	}
+	for _, key := range indexed {
+		if _, found := cache.Get(key); !found {
+			t.Errorf("indexed key %s is not cached", key)
+		}
+	}
+
+	for _, key := range indexed {
+		cache.Delete(key)
+	}
+	if keys := cache.Keys(); len(keys) != 0 {
+		t.Errorf("Keys() after Delete = %v, want none", keys)
+	}
+}
+
+func TestRistrettoCacheListingSkipsMetrics(t *testing.T) {
+	cache, err := NewRistrettoCache(&ristretto.Config{NumCounters: 1e3, MaxCost: 1 << 20, BufferItems: 64, Metrics: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	cache.SetWithTTL("zones", []byte("1234"), time.Hour)
+	cache.SetWithTTL("apr", []byte("12"), 0)
+	cache.Wait()
+
+	entries := map[string]CacheEntry{}
+	for _, entry := range cache.Entries("") {
+		entries[entry.Key] = entry
+	}
+	if len(cache.Keys()) != 2 || len(entries) != 2 {
+		t.Fatalf("Entries() = %v, want zones and apr", entries)
+	}
+	if entries["zones"].Size != 4 || entries["apr"].TTLSeconds != -1 {
+		t.Errorf("Entries() = %v, want the value sizes and -1 for no expiry", entries)
+	}
+	if hits, misses := cache.Metrics.Hits(), cache.Metrics.Misses(); hits != 0 || misses != 0 {
+		t.Errorf("listing counted %d hits and %d misses, want none", hits, misses)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+)
+
+const defaultChainDecimals = 6
+
+// ChainConfig describes a chain evince serves. RPC and LCD default to the
+// chain_rpc_endpoint and chain_lcd_endpoint templates filled with the chain id;
+// ValoperPrefix defaults to Bech32Prefix + "valoper".
+type ChainConfig struct {
+	ChainID       string `yaml:"chain_id" json:"chain_id"`
+	Name          string `yaml:"name" json:"name"`
+	RPC           string `yaml:"rpc" json:"rpc"`
+	LCD           string `yaml:"lcd" json:"lcd"`
+	Bech32Prefix  string `yaml:"bech32_prefix" json:"bech32_prefix"`
+	ValoperPrefix string `yaml:"valoper_prefix" json:"valoper_prefix"`
+	BaseDenom     string `yaml:"base_denom" json:"base_denom"`
+	Decimals      int    `yaml:"decimals" json:"decimals"`
+}
+
+// ChainRegistry resolves chain ids from requests to configured chains, so that
+// endpoints are never built from user input.
+type ChainRegistry struct {
+	chains map[string]ChainConfig
+}
+
+// NewChainRegistry validates the configured chains and fills in their defaults.
+func NewChainRegistry(cfg Config) (*ChainRegistry, error) {
+	registry := &ChainRegistry{chains: map[string]ChainConfig{}}
+
+	for _, chain := range cfg.ChainRegistry {
+		if chain.ChainID == "" {
+			return nil, fmt.Errorf("chain without chain_id")
+		}
+		if _, ok := registry.chains[chain.ChainID]; ok {
+			return nil, fmt.Errorf("duplicate chain %s", chain.ChainID)
+		}
+		if chain.Bech32Prefix == "" {
+			return nil, fmt.Errorf("chain %s has no bech32_prefix", chain.ChainID)
+		}
+
+		if chain.RPC == "" {
+			if cfg.ChainHost == "" {
+				return nil, fmt.Errorf("chain %s has no rpc and chain_rpc_endpoint is not set", chain.ChainID)
+			}
+			chain.RPC = fmt.Sprintf(cfg.ChainHost, chain.ChainID)
+		}
+		if chain.LCD == "" && cfg.ChainLcdHost != "" {
+			chain.LCD = fmt.Sprintf(cfg.ChainLcdHost, chain.ChainID)
+		}
+		chain.LCD = strings.TrimSuffix(chain.LCD, "/")
+		if chain.ValoperPrefix == "" {
+			chain.ValoperPrefix = chain.Bech32Prefix + "valoper"
+		}
+		if chain.Decimals == 0 {
+			chain.Decimals = defaultChainDecimals
+		}
+
+		registry.chains[chain.ChainID] = chain
+	}
+
+	return registry, nil
+}
+
+// Lookup returns the chain registered under chainId, or an unknown chain error.
+func (r *ChainRegistry) Lookup(chainId string) (ChainConfig, error) {
+	if r != nil {
+		if chain, ok := r.chains[chainId]; ok {
+			return chain, nil
+		}
+	}
+
+	apiErr := sentinelError(ErrUnknownChain)
+	apiErr.Message = fmt.Sprintf("%s: %s", ErrUnknownChain, chainId)
+	return ChainConfig{}, apiErr
+}
+
+// decodeAccountAddress decodes a bech32 account address of any chain into its
+// account bytes. The error explains why the address was rejected.
+func decodeAccountAddress(address string) ([]byte, error) {
+	hrp, data, err := bech32.DecodeAndConvert(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bech32 address %q: %v", address, err)
+	}
+	if strings.HasSuffix(hrp, "valoper") || strings.HasSuffix(hrp, "valcons") {
+		return nil, fmt.Errorf("%q is a validator address, expected an account address", address)
+	}
+	// 20 byte key addresses or 32 byte module and interchain accounts
+	if len(data) != 20 && len(data) != 32 {
+		return nil, fmt.Errorf("invalid address %q: %d byte accounts are not supported", address, len(data))
+	}
+	return data, nil
+}
+
+// NormalizeAddress re-encodes a bech32 account address with the chain's account
+// prefix, so an address from any chain resolves to the same account bytes here.
+func (c ChainConfig) NormalizeAddress(address string) (string, error) {
+	data, err := decodeAccountAddress(address)
+	if err != nil {
+		return "", err
+	}
+	return bech32.ConvertAndEncode(c.Bech32Prefix, data)
+}
+
+// Chains returns every registered chain ordered by chain id.
+func (r *ChainRegistry) Chains() []ChainConfig {
+	if r == nil {
+		return nil
+	}
+
+	chains := make([]ChainConfig, 0, len(r.chains))
+	for _, chain := range r.chains {
+		chains = append(chains, chain)
+	}
+	sort.Slice(chains, func(i, j int) bool { return chains[i].ChainID < chains[j].ChainID })
+	return chains
+}
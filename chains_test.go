@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	"gopkg.in/yaml.v2"
+)
+
+func mustBech32(t *testing.T, hrp string, data []byte) string {
+	t.Helper()
+
+	address, err := bech32.ConvertAndEncode(hrp, data)
+	if err != nil {
+		t.Fatalf("encode %s address: %v", hrp, err)
+	}
+	return address
+}
+
+func TestDecodeAccountAddress(t *testing.T) {
+	key := bytes.Repeat([]byte{0x01}, 20)
+	module := bytes.Repeat([]byte{0x02}, 32)
+
+	cosmos := mustBech32(t, "cosmos", key)
+	// flip the last checksum character
+	last := cosmos[len(cosmos)-1]
+	flipped := byte('q')
+	if last == 'q' {
+		flipped = 'p'
+	}
+	badChecksum := cosmos[:len(cosmos)-1] + string(flipped)
+
+	tests := []struct {
+		name    string
+		address string
+		want    []byte
+		wantErr string
+	}{
+		{name: "key account", address: cosmos, want: key},
+		{name: "other chain prefix", address: mustBech32(t, "osmo", key), want: key},
+		{name: "module account", address: mustBech32(t, "quick", module), want: module},
+		{name: "bad checksum", address: badChecksum, wantErr: "invalid bech32 address"},
+		{name: "not bech32", address: "cosmos", wantErr: "invalid bech32 address"},
+		{name: "valoper", address: mustBech32(t, "cosmosvaloper", key), wantErr: "is a validator address"},
+		{name: "valcons", address: mustBech32(t, "cosmosvalcons", key), wantErr: "is a validator address"},
+		{name: "unsupported length", address: mustBech32(t, "cosmos", key[:16]), wantErr: "16 byte accounts are not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeAccountAddress(tt.address)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decodeAccountAddress(%q) error = %v, want %q", tt.address, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeAccountAddress(%q): %v", tt.address, err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("decodeAccountAddress(%q) = %x, want %x", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	chain := ChainConfig{ChainID: "cosmoshub-4", Bech32Prefix: "cosmos"}
+	key := bytes.Repeat([]byte{0x03}, 20)
+	cosmos := mustBech32(t, "cosmos", key)
+
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		wantErr bool
+	}{
+		{name: "same prefix", address: cosmos, want: cosmos},
+		{name: "from another chain", address: mustBech32(t, "osmo", key), want: cosmos},
+		{name: "from quicksilver", address: mustBech32(t, "quick", key), want: cosmos},
+		{name: "upper case", address: strings.ToUpper(mustBech32(t, "osmo", key)), want: cosmos},
+		{name: "valoper", address: mustBech32(t, "cosmosvaloper", key), wantErr: true},
+		{name: "bad checksum", address: cosmos[:len(cosmos)-2] + "zz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := chain.NormalizeAddress(tt.address)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("NormalizeAddress(%q) = %q, want an error", tt.address, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeAddress(%q): %v", tt.address, err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeAddress(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewChainRegistry(t *testing.T) {
+	cfg := Config{
+		ChainHost:    "https://%s.rpc.example.com:443",
+		ChainLcdHost: "https://%s.lcd.example.com/",
+		ChainRegistry: []ChainConfig{
+			{ChainID: "cosmoshub-4", Name: "cosmoshub", Bech32Prefix: "cosmos", BaseDenom: "uatom"},
+			{ChainID: "dydx-mainnet-1", Name: "dydx", Bech32Prefix: "dydx", BaseDenom: "adydx", Decimals: 18, RPC: "https://dydx.example.com", LCD: "https://dydx-lcd.example.com/", ValoperPrefix: "dydxop"},
+		},
+	}
+
+	registry, err := NewChainRegistry(cfg)
+	if err != nil {
+		t.Fatalf("NewChainRegistry: %v", err)
+	}
+
+	want := []ChainConfig{
+		{ChainID: "cosmoshub-4", Name: "cosmoshub", RPC: "https://cosmoshub-4.rpc.example.com:443", LCD: "https://cosmoshub-4.lcd.example.com", Bech32Prefix: "cosmos", ValoperPrefix: "cosmosvaloper", BaseDenom: "uatom", Decimals: 6},
+		{ChainID: "dydx-mainnet-1", Name: "dydx", RPC: "https://dydx.example.com", LCD: "https://dydx-lcd.example.com", Bech32Prefix: "dydx", ValoperPrefix: "dydxop", BaseDenom: "adydx", Decimals: 18},
+	}
+	if got := registry.Chains(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Chains() = %+v, want %+v", got, want)
+	}
+
+	invalid := []struct {
+		name string
+		cfg  Config
+	}{
+		{"missing chain id", Config{ChainHost: "%s", ChainRegistry: []ChainConfig{{Bech32Prefix: "cosmos"}}}},
+		{"missing prefix", Config{ChainHost: "%s", ChainRegistry: []ChainConfig{{ChainID: "cosmoshub-4"}}}},
+		{"duplicate", Config{ChainHost: "%s", ChainRegistry: []ChainConfig{{ChainID: "cosmoshub-4", Bech32Prefix: "cosmos"}, {ChainID: "cosmoshub-4", Bech32Prefix: "cosmos"}}}},
+		{"no rpc", Config{ChainRegistry: []ChainConfig{{ChainID: "cosmoshub-4", Bech32Prefix: "cosmos"}}}},
+	}
+	for _, tt := range invalid {
+		if _, err := NewChainRegistry(tt.cfg); err == nil {
+			t.Errorf("NewChainRegistry(%s) succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestChainRegistryLookup(t *testing.T) {
+	registry, err := NewChainRegistry(Config{
+		ChainHost:     "https://%s.rpc.example.com:443",
+		ChainRegistry: []ChainConfig{{ChainID: "osmosis-1", Bech32Prefix: "osmo"}},
+	})
+	if err != nil {
+		t.Fatalf("NewChainRegistry: %v", err)
+	}
+
+	chain, err := registry.Lookup("osmosis-1")
+	if err != nil {
+		t.Fatalf("Lookup(osmosis-1): %v", err)
+	}
+	if chain.RPC != "https://osmosis-1.rpc.example.com:443" {
+		t.Errorf("osmosis-1 rpc = %q", chain.RPC)
+	}
+
+	for _, chainId := range []string{"cosmoshub-4", "", "Osmosis-1", "osmosis-1.evil.com"} {
+		_, err := registry.Lookup(chainId)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound || !errors.Is(err, ErrUnknownChain) {
+			t.Errorf("Lookup(%q) error = %v, want an unknown chain 404", chainId, err)
+		}
+	}
+
+	var nilRegistry *ChainRegistry
+	if _, err := nilRegistry.Lookup("osmosis-1"); !errors.Is(err, ErrUnknownChain) {
+		t.Errorf("nil registry Lookup error = %v, want unknown chain", err)
+	}
+}
+
+// TestConfiguredZonesAreRegistered guards against a zone in chains that the
+// per-chain endpoints would reject as unknown.
+func TestConfiguredZonesAreRegistered(t *testing.T) {
+	data, err := os.ReadFile("conf.yaml")
+	if err != nil {
+		t.Fatalf("read conf.yaml: %v", err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("parse conf.yaml: %v", err)
+	}
+	registry, err := NewChainRegistry(cfg)
+	if err != nil {
+		t.Fatalf("NewChainRegistry: %v", err)
+	}
+
+	registered := map[string]bool{}
+	for _, chain := range registry.Chains() {
+		registered[chain.Name] = true
+	}
+	for _, name := range cfg.Chains {
+		if !registered[name] {
+			t.Errorf("zone %s is not in chain_registry", name)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	cdctypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/types/query"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	distrtypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	tmhttp "github.com/tendermint/tendermint/rpc/client/http"
+)
+
+const (
+	IncludeUnbonding     = "unbonding"
+	IncludeRedelegations = "redelegations"
+	IncludeRewards       = "rewards"
+	IncludeBalances      = "balances"
+)
+
+// parseDelegationIncludes reads the comma separated include parameter of
+// /existingDelegations, returning it sorted and deduplicated.
+func parseDelegationIncludes(raw string) ([]string, error) {
+	seen := map[string]struct{}{}
+	includes := []string{}
+	for _, include := range strings.Split(raw, ",") {
+		include = strings.TrimSpace(include)
+		if include == "" {
+			continue
+		}
+		switch include {
+		case IncludeUnbonding, IncludeRedelegations, IncludeRewards, IncludeBalances:
+		default:
+			return nil, fmt.Errorf("invalid include %q: expected %s, %s, %s or %s", include, IncludeUnbonding, IncludeRedelegations, IncludeRewards, IncludeBalances)
+		}
+		if _, ok := seen[include]; !ok {
+			seen[include] = struct{}{}
+			includes = append(includes, include)
+		}
+	}
+	sort.Strings(includes)
+	return includes, nil
+}
+
+// queryAllPages runs a paginated ABCI query at height, following next_key until
+// the last page. request builds the query for a page; decode unmarshals a
+// response, accumulating its results, and returns its pagination. A height of
+// zero reads the latest height, and later pages are pinned to the height of the
+// first so that all pages come from the same state. The height read at is
+// returned.
+func queryAllPages(
+	client *tmhttp.HTTP,
+	path string,
+	height int64,
+	request func(page *query.PageRequest) []byte,
+	decode func(value []byte) (*query.PageResponse, error),
+) (int64, error) {
+	var page *query.PageRequest
+	for {
+		abciquery, err := client.ABCIQueryWithOptions(
+			context.Background(),
+			path,
+			request(page),
+			rpcclient.ABCIQueryOptions{Height: height},
+		)
+		if err != nil {
+			return 0, err
+		}
+		if !abciquery.Response.IsOK() {
+			return 0, fmt.Errorf("%s failed with code %d: %s", path, abciquery.Response.Code, abciquery.Response.Log)
+		}
+		height = abciquery.Response.Height
+
+		pagination, err := decode(abciquery.Response.Value)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrUnmarshalResponse, err)
+		}
+		if pagination == nil || len(pagination.NextKey) == 0 {
+			return height, nil
+		}
+		page = &query.PageRequest{Key: pagination.NextKey}
+	}
+}
+
+// getExistingDelegations returns every delegation of address at height, across
+// all pages. includes adds the delegator's unbonding delegations, redelegations,
+// pending rewards and bank balances, read at the same height, to the same
+// response.
+func (s *Service) getExistingDelegations(key string, chain ChainConfig, address string, includes []string, height int64) ([]byte, error) {
+	s.Echo.Logger.Infof("getExistingDelegations")
+
+	upstream := chain.ChainID + "-rpc"
+
+	// establish client connection
+	client, err := NewRPCClient(chain.RPC, 30*time.Second)
+	if err != nil {
+		s.Echo.Logger.Errorf("getExistingDelegations: %v - %v", ErrRPCClientConnection, err)
+		return nil, upstreamError(ErrRPCClientConnection, upstream, err)
+	}
+	if err := checkHeight(client, height, upstream); err != nil {
+		return nil, err
+	}
+
+	// prepare codecs
+	interfaceRegistry := cdctypes.NewInterfaceRegistry()
+	stakingtypes.RegisterInterfaces(interfaceRegistry)
+	marshaler := codec.NewProtoCodec(interfaceRegistry)
+
+	delegations, queryHeight, err := queryDelegations(client, marshaler, address, height)
+	if err != nil {
+		return nil, s.queryError("getExistingDelegations", upstream, err)
+	}
+	queryResponse := stakingtypes.QueryDelegatorDelegationsResponse{
+		DelegationResponses: delegations,
+		Pagination:          &query.PageResponse{Total: uint64(len(delegations))},
+	}
+
+	// encode response & cache
+	respdata, err := marshaler.MarshalJSON(&queryResponse)
+	if err != nil {
+		s.Echo.Logger.Errorf("getExistingDelegations: %v - %v", ErrMarshalResponse, err)
+		return nil, ErrMarshalResponse
+	}
+
+	if len(includes) > 0 {
+		var combined map[string]json.RawMessage
+		if err := json.Unmarshal(respdata, &combined); err != nil {
+			s.Echo.Logger.Errorf("getExistingDelegations: %v - %v", ErrMarshalResponse, err)
+			return nil, ErrMarshalResponse
+		}
+
+		for _, include := range includes {
+			var field string
+			var data []byte
+			switch include {
+			case IncludeUnbonding:
+				field = "unbonding_responses"
+				data, err = s.queryUnbondingDelegations(client, marshaler, address, queryHeight)
+			case IncludeRedelegations:
+				field = "redelegation_responses"
+				data, err = s.queryRedelegations(client, marshaler, address, queryHeight)
+			case IncludeRewards:
+				field = "rewards"
+				data, err = s.queryDelegationRewards(client, marshaler, address, queryHeight)
+			case IncludeBalances:
+				field = "balances"
+				data, err = s.queryDelegatorBalances(client, marshaler, address, queryHeight)
+			}
+			if err != nil {
+				return nil, s.queryError("getExistingDelegations", upstream, err)
+			}
+			combined[field] = data
+		}
+
+		respdata, err = json.Marshal(combined)
+		if err != nil {
+			s.Echo.Logger.Errorf("getExistingDelegations: %v - %v", ErrMarshalResponse, err)
+			return nil, ErrMarshalResponse
+		}
+	}
+
+	s.setCachedAtHeight(key, respdata, height, 2*time.Minute, s.chainMeta(client, queryHeight))
+
+	return respdata, nil
+}
+
+// queryDelegations returns every delegation of address at height, and the
+// height they were read at.
+func queryDelegations(client *tmhttp.HTTP, marshaler *codec.ProtoCodec, address string, height int64) (stakingtypes.DelegationResponses, int64, error) {
+	delegations := stakingtypes.DelegationResponses{}
+	height, err := queryAllPages(client, "/cosmos.staking.v1beta1.Query/DelegatorDelegations", height,
+		func(page *query.PageRequest) []byte {
+			return marshaler.MustMarshal(&stakingtypes.QueryDelegatorDelegationsRequest{DelegatorAddr: address, Pagination: page})
+		},
+		func(value []byte) (*query.PageResponse, error) {
+			pageResponse := stakingtypes.QueryDelegatorDelegationsResponse{}
+			if err := marshaler.Unmarshal(value, &pageResponse); err != nil {
+				return nil, err
+			}
+			delegations = append(delegations, pageResponse.DelegationResponses...)
+			return pageResponse.Pagination, nil
+		},
+	)
+	return delegations, height, err
+}
+
+// queryError logs a failed ABCI query made by caller and reports it against upstream.
+func (s *Service) queryError(caller string, upstream string, err error) error {
+	sentinel := ErrABCIQuery
+	if errors.Is(err, ErrUnmarshalResponse) {
+		sentinel = ErrUnmarshalResponse
+	}
+	s.Echo.Logger.Errorf("%s: %v - %v", caller, sentinel, err)
+	return upstreamError(sentinel, upstream, err)
+}
+
+// queryUnbondingDelegations returns the JSON encoded unbonding delegations of address.
+func (s *Service) queryUnbondingDelegations(client *tmhttp.HTTP, marshaler *codec.ProtoCodec, address string, height int64) ([]byte, error) {
+	queryResponse := stakingtypes.QueryDelegatorUnbondingDelegationsResponse{}
+	_, err := queryAllPages(client, "/cosmos.staking.v1beta1.Query/DelegatorUnbondingDelegations", height,
+		func(page *query.PageRequest) []byte {
+			return marshaler.MustMarshal(&stakingtypes.QueryDelegatorUnbondingDelegationsRequest{DelegatorAddr: address, Pagination: page})
+		},
+		func(value []byte) (*query.PageResponse, error) {
+			pageResponse := stakingtypes.QueryDelegatorUnbondingDelegationsResponse{}
+			if err := marshaler.Unmarshal(value, &pageResponse); err != nil {
+				return nil, err
+			}
+			queryResponse.UnbondingResponses = append(queryResponse.UnbondingResponses, pageResponse.UnbondingResponses...)
+			return pageResponse.Pagination, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	queryResponse.Pagination = nil
+	return protoJSONField(marshaler, &queryResponse, "unbonding_responses")
+}
+
+// queryRedelegations returns the JSON encoded redelegations of address.
+func (s *Service) queryRedelegations(client *tmhttp.HTTP, marshaler *codec.ProtoCodec, address string, height int64) ([]byte, error) {
+	queryResponse := stakingtypes.QueryRedelegationsResponse{}
+	_, err := queryAllPages(client, "/cosmos.staking.v1beta1.Query/Redelegations", height,
+		func(page *query.PageRequest) []byte {
+			return marshaler.MustMarshal(&stakingtypes.QueryRedelegationsRequest{DelegatorAddr: address, Pagination: page})
+		},
+		func(value []byte) (*query.PageResponse, error) {
+			pageResponse := stakingtypes.QueryRedelegationsResponse{}
+			if err := marshaler.Unmarshal(value, &pageResponse); err != nil {
+				return nil, err
+			}
+			queryResponse.RedelegationResponses = append(queryResponse.RedelegationResponses, pageResponse.RedelegationResponses...)
+			return pageResponse.Pagination, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	queryResponse.Pagination = nil
+	return protoJSONField(marshaler, &queryResponse, "redelegation_responses")
+}
+
+// queryDelegatorBalances returns the JSON encoded bank balances of address.
+func (s *Service) queryDelegatorBalances(client *tmhttp.HTTP, marshaler *codec.ProtoCodec, address string, height int64) ([]byte, error) {
+	balances, err := queryBalances(client, marshaler, address, height)
+	if err != nil {
+		return nil, err
+	}
+	return protoJSONField(marshaler, &banktypes.QueryAllBalancesResponse{Balances: balances}, "balances")
+}
+
+// protoJSONField encodes msg as proto JSON and returns a single field of it, so
+// nested types get the same encoding as a whole response would.
+func protoJSONField(marshaler *codec.ProtoCodec, msg codec.ProtoMarshaler, field string) ([]byte, error) {
+	data, err := marshaler.MarshalJSON(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields[field], nil
+}
+
+// queryDelegationRewards returns the JSON encoded pending rewards of address,
+// per validator and in total.
+func (s *Service) queryDelegationRewards(client *tmhttp.HTTP, marshaler *codec.ProtoCodec, address string, height int64) ([]byte, error) {
+	abciquery, err := client.ABCIQueryWithOptions(
+		context.Background(),
+		"/cosmos.distribution.v1beta1.Query/DelegationTotalRewards",
+		marshaler.MustMarshal(&distrtypes.QueryDelegationTotalRewardsRequest{DelegatorAddress: address}),
+		rpcclient.ABCIQueryOptions{Height: height},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !abciquery.Response.IsOK() {
+		return nil, fmt.Errorf("DelegationTotalRewards failed with code %d: %s", abciquery.Response.Code, abciquery.Response.Log)
+	}
+
+	queryResponse := distrtypes.QueryDelegationTotalRewardsResponse{}
+	if err := marshaler.Unmarshal(abciquery.Response.Value, &queryResponse); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnmarshalResponse, err)
+	}
+	return marshaler.MarshalJSON(&queryResponse)
+}
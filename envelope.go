@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	echov4 "github.com/labstack/echo/v4"
+	tmhttp "github.com/tendermint/tendermint/rpc/client/http"
+)
+
+// Headers describing the chain state a response was read from.
+const (
+	HeaderEvinceHeight    = "X-Evince-Height"
+	HeaderEvinceChainID   = "X-Evince-Chain-Id"
+	HeaderEvinceBlockTime = "X-Evince-Block-Time"
+
+	// HeaderCosmosBlockHeight is the height an LCD response was read at.
+	HeaderCosmosBlockHeight = "Grpc-Metadata-X-Cosmos-Block-Height"
+)
+
+// ChainMeta identifies the chain state a response was read from.
+type ChainMeta struct {
+	ChainID   string
+	Height    int64
+	BlockTime time.Time
+}
+
+// Envelope wraps a chain-derived response with where and when it was read.
+// ExpiresAt is null for responses that are cached indefinitely.
+type Envelope struct {
+	Data      json.RawMessage `json:"data"`
+	ChainID   string          `json:"chain_id,omitempty"`
+	Height    int64           `json:"height,omitempty"`
+	BlockTime *time.Time      `json:"block_time,omitempty"`
+	FetchedAt *time.Time      `json:"fetched_at,omitempty"`
+	ExpiresAt *time.Time      `json:"expires_at"`
+}
+
+// chainMeta describes the state at height of the chain client is connected to.
+// The block header is fetched on a best effort basis; without it only the
+// height is known.
+func (s *Service) chainMeta(client *tmhttp.HTTP, height int64) ChainMeta {
+	meta := ChainMeta{Height: height}
+
+	commit, err := client.Commit(context.Background(), &height)
+	if err == nil && commit.Header == nil {
+		err = fmt.Errorf("commit has no header")
+	}
+	if err != nil {
+		s.Echo.Logger.Warnf("chainMeta: unable to get block header at %d - %v", height, err)
+		return meta
+	}
+	meta.ChainID = commit.Header.ChainID
+	meta.BlockTime = commit.Header.Time
+	return meta
+}
+
+// quicksilverMeta is chainMeta for the Quicksilver RPC endpoint. Nothing is
+// known for a zero height.
+func (s *Service) quicksilverMeta(height int64) ChainMeta {
+	if height == 0 {
+		return ChainMeta{}
+	}
+
+	client, err := NewRPCClient(s.Config.RpcEndpoint, 30*time.Second)
+	if err != nil {
+		s.Echo.Logger.Warnf("quicksilverMeta: %v - %v", ErrRPCClientConnection, err)
+		return ChainMeta{Height: height}
+	}
+	return s.chainMeta(client, height)
+}
+
+// parseEnvelope reads the optional envelope parameter.
+func parseEnvelope(ctx echov4.Context) (bool, error) {
+	raw := ctx.QueryParam("envelope")
+	if raw == "" {
+		return false, nil
+	}
+
+	envelope, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid envelope %q: expected true or false", raw)
+	}
+	return envelope, nil
+}
+
+// serveChainCached is serveCached for chain-derived responses: the chain state
+// the response was read from is reported in headers and, with ?envelope=true,
+// in an envelope around the response.
+func (s *Service) serveChainCached(ctx echov4.Context, key string, fetch func() ([]byte, error)) error {
+	envelope, err := parseEnvelope(ctx)
+	if err != nil {
+		return invalidParameter(err)
+	}
+
+	data, err := s.cached(ctx, key, fetch)
+	if err != nil {
+		return err
+	}
+
+	return s.writeChainResponse(ctx, key, data, envelope)
+}
+
+// cachedChainState returns where and when the response cached under key was
+// read, or the zero value when that is unknown. Its Data is not set for
+// responses pinned to a past height.
+func (s *Service) cachedChainState(key string) cachedResponse {
+	if entry, found := s.lookupChainState(key); found {
+		return entry
+	}
+	// the response may have just been fetched; apply the cache's pending sets
+	if pending, ok := s.Cache.(interface{ Wait() }); ok {
+		pending.Wait()
+		if entry, found := s.lookupChainState(key); found {
+			return entry
+		}
+	}
+	return cachedResponse{}
+}
+
+func (s *Service) lookupChainState(key string) (cachedResponse, bool) {
+	if value, found := s.Cache.Get(staleKeyPrefix + key); found {
+		return value.(cachedResponse), true
+	}
+	if value, found := s.Cache.Get(metaKeyPrefix + key); found {
+		return value.(cachedResponse), true
+	}
+	return cachedResponse{}, false
+}
+
+// writeChainResponse responds with data, derived from the response cached
+// under key, along with the chain state that response was read from.
+func (s *Service) writeChainResponse(ctx echov4.Context, key string, data []byte, envelope bool) error {
+	entry := s.cachedChainState(key)
+
+	header := ctx.Response().Header()
+	if entry.Height > 0 {
+		header.Set(HeaderEvinceHeight, strconv.FormatInt(entry.Height, 10))
+	}
+	if entry.ChainID != "" {
+		header.Set(HeaderEvinceChainID, entry.ChainID)
+	}
+	if !entry.BlockTime.IsZero() {
+		header.Set(HeaderEvinceBlockTime, entry.BlockTime.UTC().Format(time.RFC3339))
+	}
+
+	if !envelope {
+		return ctx.JSONBlob(http.StatusOK, data)
+	}
+
+	resp := Envelope{
+		Data:    data,
+		ChainID: entry.ChainID,
+		Height:  entry.Height,
+	}
+	if !entry.BlockTime.IsZero() {
+		resp.BlockTime = &entry.BlockTime
+	}
+	if !entry.FetchedAt.IsZero() {
+		resp.FetchedAt = &entry.FetchedAt
+	}
+	if !entry.ExpiresAt.IsZero() {
+		resp.ExpiresAt = &entry.ExpiresAt
+	}
+	return ctx.JSON(http.StatusOK, resp)
+}
@@ -14,4 +14,20 @@ var (
 	ErrUnableToGetLockedTokens  = errors.New("unable to get locked tokens response")
 	ErrUnableToGetTotalSupply   = errors.New("unable to get total supply response")
 	ErrUnableToGetCommunityPool = errors.New("unable to get CommunityPool response")
+	ErrUnknownAPRProvider       = errors.New("unknown apr provider")
+	ErrUnableToGetCommission    = errors.New("unable to get interchainstaking commission rate")
+	ErrOpenHistoryStore         = errors.New("unable to open history store")
+	ErrHistoryDisabled          = errors.New("history store is not configured")
+	ErrUnableToGetPrices        = errors.New("unable to get prices response")
+	ErrUnknownPriceSource       = errors.New("unknown price source")
+	ErrUnknownPricePolicy       = errors.New("unknown price aggregation policy")
+	ErrUnableToGetTopAccounts   = errors.New("unable to get top accounts response")
+	ErrLoadSnapshot             = errors.New("unable to load cache snapshot")
+	ErrSaveSnapshot             = errors.New("unable to save cache snapshot")
+	ErrUnknownCacheBackend      = errors.New("unknown cache backend")
+	ErrCacheNotInspectable      = errors.New("cache backend cannot list keys")
+	ErrUnknownChain             = errors.New("unknown chain")
+	ErrInvalidChainRegistry     = errors.New("invalid chain registry")
+	ErrUnableToGetSigningInfos  = errors.New("unable to get validator signing infos")
+	ErrRateLimited              = errors.New("too many requests")
 )
@@ -8,11 +8,9 @@ import (
 	"html/template"
 	"image/png"
 	"io"
-	"log"
 	"math"
 	"math/big"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -32,77 +30,147 @@ import (
 )
 
 func (s *Service) ConfigureRoutes() {
+	s.configureAdminRoutes()
+
 	s.Echo.GET("/", func(ctx echov4.Context) error {
 		output := fmt.Sprintf("Quicksilver (evince): %v\n%v", GitCommit, LogoStr)
 		return ctx.String(http.StatusOK, output)
 	})
 
 	s.Echo.GET("/validatorList/:chainId", func(ctx echov4.Context) error {
-		chainId := ctx.Param("chainId")
+		chain, err := s.Registry.Lookup(ctx.Param("chainId"))
+		if err != nil {
+			return err
+		}
 
-		key := fmt.Sprintf("validatorList.%s", chainId)
+		query, err := parseValidatorQuery(ctx)
+		if err != nil {
+			return invalidParameter(err)
+		}
 
-		data, found := s.Cache.Get(key)
-		if !found {
-			return s.getValidatorList(ctx, key, chainId)
+		height, err := parseHeight(ctx)
+		if err != nil {
+			return invalidParameter(err)
 		}
 
-		return ctx.JSONBlob(http.StatusOK, data.([]byte))
+		key := heightKey(validatorListKey(chain.ChainID), height)
+
+		return s.serveValidatorList(ctx, key, query, func() ([]byte, error) {
+			return s.getValidatorList(key, chain, height)
+		})
 	})
 
-	s.Echo.GET("/existingDelegations/:chainId/:address", func(c echov4.Context) error {
-		chainId := c.Param("chainId")
-		address := c.Param("address")
+	s.Echo.GET("/validatorList/:chainId/performance", func(ctx echov4.Context) error {
+		chain, err := s.Registry.Lookup(ctx.Param("chainId"))
+		if err != nil {
+			return err
+		}
 
-		key := fmt.Sprintf("existingDelegations.%s.%s", chainId, address)
+		key := validatorPerformanceKey(chain.ChainID)
 
-		data, found := s.Cache.Get(key)
-		if !found {
-			return s.getExistingDelegations(c, key, chainId, address)
+		return s.serveChainCached(ctx, key, func() ([]byte, error) {
+			return s.getValidatorPerformance(key, chain)
+		})
+	})
+
+	s.Echo.GET("/existingDelegations/:chainId/:address", func(c echov4.Context) error {
+		chain, err := s.Registry.Lookup(c.Param("chainId"))
+		if err != nil {
+			return err
+		}
+		address, err := chain.NormalizeAddress(c.Param("address"))
+		if err != nil {
+			return invalidParameter(err)
 		}
+		includes, err := parseDelegationIncludes(c.QueryParam("include"))
+		if err != nil {
+			return invalidParameter(err)
+		}
+		height, err := parseHeight(c)
+		if err != nil {
+			return invalidParameter(err)
+		}
+
+		key := fmt.Sprintf("existingDelegations.%s.%s", chain.ChainID, address)
+		if len(includes) > 0 {
+			key += "." + strings.Join(includes, ",")
+		}
+		key = heightKey(key, height)
 
-		return c.JSONBlob(http.StatusOK, data.([]byte))
+		return s.serveChainCached(c, key, func() ([]byte, error) {
+			return s.getExistingDelegations(key, chain, address, includes, height)
+		})
 	})
 
+	s.Echo.GET("/portfolio/:address", func(ctx echov4.Context) error {
+		account, err := decodeAccountAddress(ctx.Param("address"))
+		if err != nil {
+			return invalidParameter(err)
+		}
+
+		key := fmt.Sprintf("portfolio.%x", account)
+
+		return s.serveCached(ctx, key, func() ([]byte, error) {
+			return s.getPortfolio(key, account)
+		})
+	}, s.portfolioRateLimiter())
+
 	s.Echo.GET("/zones", func(ctx echov4.Context) error {
-		key := "zones"
+		height, err := parseHeight(ctx)
+		if err != nil {
+			return invalidParameter(err)
+		}
 
-		data, found := s.Cache.Get(key)
-		if !found {
-			return s.getZones(ctx, key)
+		key := heightKey("zones", height)
+
+		return s.serveChainCached(ctx, key, func() ([]byte, error) {
+			return s.getZones(key, height)
+		})
+	})
+
+	s.Echo.GET("/zones/:chainId/validators", func(ctx echov4.Context) error {
+		chain, err := s.Registry.Lookup(ctx.Param("chainId"))
+		if err != nil {
+			return err
 		}
 
-		return ctx.JSONBlob(http.StatusOK, data.([]byte))
+		key := fmt.Sprintf("zoneValidators.%s", chain.ChainID)
+
+		return s.serveChainCached(ctx, key, func() ([]byte, error) {
+			return s.getZoneValidators(key, chain)
+		})
 	})
 
 	s.Echo.GET("/apr", func(ctx echov4.Context) error {
 		key := "apr"
 
-		data, found := s.Cache.Get(key)
-		if !found {
-			return s.getAPR(ctx, key)
-		}
-		return ctx.JSONBlob(http.StatusOK, data.([]byte))
+		return s.serveCached(ctx, key, func() ([]byte, error) {
+			return s.getAPR(key)
+		})
+	})
+
+	s.Echo.GET("/apr/history/:chainId", func(ctx echov4.Context) error {
+		return s.getAPRHistory(ctx, ctx.Param("chainId"))
+	})
+
+	s.Echo.GET("/prices/history/:symbol", func(ctx echov4.Context) error {
+		return s.getPriceHistory(ctx, ctx.Param("symbol"))
 	})
 
 	s.Echo.GET("/total_supply", func(ctx echov4.Context) error {
 		key := "total_supply"
 
-		data, found := s.Cache.Get(key)
-		if !found {
-			return s.getSupply(ctx, key)
-		}
-		return ctx.JSONBlob(http.StatusOK, data.([]byte))
+		return s.serveChainCached(ctx, key, func() ([]byte, error) {
+			return s.getSupply(key)
+		})
 	})
 
 	s.Echo.GET("/circulating_supply", func(ctx echov4.Context) error {
 		key := "circulating_supply"
 
-		data, found := s.Cache.Get(key)
-		if !found {
-			return s.getCirculatingSupply(ctx, key)
-		}
-		return ctx.JSONBlob(http.StatusOK, data.([]byte))
+		return s.serveChainCached(ctx, key, func() ([]byte, error) {
+			return s.getCirculatingSupply(key)
+		})
 	})
 
 	s.Echo.GET("/top100/json", func(ctx echov4.Context) error {
@@ -114,20 +182,24 @@ func (s *Service) ConfigureRoutes() {
 	})
 
 	s.Echo.GET("/prices", func(ctx echov4.Context) error {
-		return s.getPrices(ctx)
+		query, err := s.parsePriceQuery(ctx)
+		if err != nil {
+			return invalidParameter(err)
+		}
+
+		key := query.cacheKey()
+
+		return s.serveCached(ctx, key, func() ([]byte, error) {
+			return s.getPrices(key, query)
+		})
 	})
 
 	s.Echo.GET("/defi", func(ctx echov4.Context) error {
+		key := "defi"
 
-		defi, err := s.doDefi(ctx)
-		if err != nil {
-			return err
-		}
-		data, err := json.Marshal(defi)
-		if err != nil {
-			return err
-		}
-		return ctx.JSONBlob(http.StatusOK, data)
+		return s.serveCached(ctx, key, func() ([]byte, error) {
+			return s.getDefi(key)
+		})
 	})
 
 	s.Echo.GET("/valoper/:chainId/:address/:h/:w", func(ctx echov4.Context) error {
@@ -135,11 +207,11 @@ func (s *Service) ConfigureRoutes() {
 		chainId := ctx.Param("chainId")
 		height, err := strconv.Atoi(ctx.Param("h"))
 		if err != nil {
-			return echov4.ErrBadRequest
+			return invalidParameter(fmt.Errorf("invalid height %q", ctx.Param("h")))
 		}
 		width, err := strconv.Atoi(ctx.Param("w"))
 		if err != nil {
-			return echov4.ErrBadRequest
+			return invalidParameter(fmt.Errorf("invalid width %q", ctx.Param("w")))
 		}
 
 		key := fmt.Sprintf("logo.%s.%s.%d.%d", chainId, address, height, width)
@@ -174,16 +246,18 @@ func (s *Service) ConfigureRoutes() {
 	})
 }
 
-func (s *Service) getValidatorList(ctx echov4.Context, key string, chainId string) error {
+// getValidatorList returns every validator of chain at height.
+func (s *Service) getValidatorList(key string, chain ChainConfig, height int64) ([]byte, error) {
 	s.Echo.Logger.Infof("getValidatorList")
 
-	host := fmt.Sprintf(s.Config.ChainHost, chainId)
-
 	// establish client connection
-	client, err := NewRPCClient(host, 30*time.Second)
+	client, err := NewRPCClient(chain.RPC, 30*time.Second)
 	if err != nil {
 		s.Echo.Logger.Errorf("getValidatorList: %v - %v", ErrRPCClientConnection, err)
-		return ErrRPCClientConnection
+		return nil, upstreamError(ErrRPCClientConnection, chain.ChainID+"-rpc", err)
+	}
+	if err := checkHeight(client, height, chain.ChainID+"-rpc"); err != nil {
+		return nil, err
 	}
 
 	// prepare codecs
@@ -192,6 +266,7 @@ func (s *Service) getValidatorList(ctx echov4.Context, key string, chainId strin
 	marshaler := codec.NewProtoCodec(interfaceRegistry)
 
 	queryResponse := stakingtypes.QueryValidatorsResponse{}
+	queryHeight := height
 	for i := 0; queryResponse.Pagination == nil || len(queryResponse.Validators) < int(queryResponse.Pagination.Total); i++ {
 		// prepare query
 		vQuery := stakingtypes.QueryValidatorsRequest{
@@ -209,17 +284,22 @@ func (s *Service) getValidatorList(ctx echov4.Context, key string, chainId strin
 			context.Background(),
 			"/cosmos.staking.v1beta1.Query/Validators",
 			qBytes,
-			rpcclient.ABCIQueryOptions{Height: 0},
+			rpcclient.ABCIQueryOptions{Height: queryHeight},
 		)
+		if err == nil && !abciquery.Response.IsOK() {
+			err = fmt.Errorf("code %d: %s", abciquery.Response.Code, abciquery.Response.Log)
+		}
 		if err != nil {
 			s.Echo.Logger.Errorf("getValidatorList: %v - %v", ErrABCIQuery, err)
-			return ErrABCIQuery
+			return nil, upstreamError(ErrABCIQuery, chain.ChainID+"-rpc", err)
 		}
+		// read every page from the state of the first
+		queryHeight = abciquery.Response.Height
 
 		// decode query response
 		if err := marshaler.Unmarshal(abciquery.Response.Value, &queryResponse); err != nil {
 			s.Echo.Logger.Errorf("getValidatorList: %v - %v", ErrUnmarshalResponse, err)
-			return ErrUnmarshalResponse
+			return nil, upstreamError(ErrUnmarshalResponse, chain.ChainID+"-rpc", err)
 		}
 	}
 
@@ -227,76 +307,48 @@ func (s *Service) getValidatorList(ctx echov4.Context, key string, chainId strin
 	respdata, err := codec.ProtoMarshalJSON(&queryResponse, nil)
 	if err != nil {
 		s.Echo.Logger.Errorf("getValidatorList: %v - %v", ErrMarshalResponse, err)
-		return ErrMarshalResponse
+		return nil, ErrMarshalResponse
 	}
 
-	s.Cache.SetWithTTL(key, respdata, 1, 1*time.Hour)
+	s.setCachedAtHeight(key, respdata, height, 1*time.Hour, s.chainMeta(client, queryHeight))
 
-	return ctx.JSONBlob(http.StatusOK, respdata)
+	return respdata, nil
 }
 
-func (s *Service) getExistingDelegations(ctx echov4.Context, key string, chainId string, address string) error {
-	s.Echo.Logger.Infof("getExistingDelegations")
-
-	host := fmt.Sprintf(s.Config.ChainHost, chainId)
+func (s *Service) getZones(key string, height int64) ([]byte, error) {
+	s.Echo.Logger.Infof("getZones")
 
-	// establish client connection
-	client, err := NewRPCClient(host, 30*time.Second)
+	queryResponse, queryHeight, err := s.queryZones(height)
 	if err != nil {
-		s.Echo.Logger.Errorf("getExistingDelegations: %v - %v", ErrRPCClientConnection, err)
-		return ErrRPCClientConnection
+		return nil, err
 	}
 
-	// prepare codecs
+	// encode response & cache
 	interfaceRegistry := cdctypes.NewInterfaceRegistry()
-	stakingtypes.RegisterInterfaces(interfaceRegistry)
+	icstypes.RegisterInterfaces(interfaceRegistry)
 	marshaler := codec.NewProtoCodec(interfaceRegistry)
-
-	// prepare query
-	query := stakingtypes.QueryDelegatorDelegationsRequest{
-		DelegatorAddr: address,
-	}
-	qBytes := marshaler.MustMarshal(&query)
-
-	// execute query
-	abciquery, err := client.ABCIQueryWithOptions(
-		context.Background(),
-		"/cosmos.staking.v1beta1.Query/DelegatorDelegations",
-		qBytes,
-		rpcclient.ABCIQueryOptions{Height: 0},
-	)
-	if err != nil {
-		s.Echo.Logger.Errorf("getExistingDelegations: %v - %v", ErrABCIQuery, err)
-		return ErrABCIQuery
-	}
-
-	// decode query response
-	queryResponse := stakingtypes.QueryDelegatorDelegationsResponse{}
-	if err := marshaler.Unmarshal(abciquery.Response.Value, &queryResponse); err != nil {
-		s.Echo.Logger.Errorf("getExistingDelegations: %v - %v", ErrUnmarshalResponse, err)
-		return ErrUnmarshalResponse
-	}
-
-	// encode response & cache
-	respdata, err := marshaler.MarshalJSON(&queryResponse)
+	respdata, err := marshaler.MarshalJSON(queryResponse)
 	if err != nil {
-		s.Echo.Logger.Errorf("getExistingDelegations: %v - %v", ErrMarshalResponse, err)
-		return ErrMarshalResponse
+		s.Echo.Logger.Errorf("getZones: %v - %v", ErrMarshalResponse, err)
+		return nil, ErrMarshalResponse
 	}
 
-	s.Cache.SetWithTTL(key, respdata, 1, 2*time.Minute)
+	s.setCachedAtHeight(key, respdata, height, 1*time.Minute, s.quicksilverMeta(queryHeight))
 
-	return ctx.JSONBlob(http.StatusOK, respdata)
+	return respdata, nil
 }
 
-func (s *Service) getZones(ctx echov4.Context, key string) error {
-	s.Echo.Logger.Infof("getZones")
-
+// queryZones returns the interchainstaking zones at height, zero meaning the
+// latest, and the height they were read at.
+func (s *Service) queryZones(height int64) (*icstypes.QueryZonesInfoResponse, int64, error) {
 	// establish client connection
 	client, err := NewRPCClient(s.Config.RpcEndpoint, 30*time.Second)
 	if err != nil {
-		s.Echo.Logger.Errorf("getZones: %v - %v", ErrRPCClientConnection, err)
-		return ErrRPCClientConnection
+		s.Echo.Logger.Errorf("queryZones: %v - %v", ErrRPCClientConnection, err)
+		return nil, 0, upstreamError(ErrRPCClientConnection, "quicksilver-rpc", err)
+	}
+	if err := checkHeight(client, height, "quicksilver-rpc"); err != nil {
+		return nil, 0, err
 	}
 
 	// prepare codecs
@@ -313,202 +365,165 @@ func (s *Service) getZones(ctx echov4.Context, key string) error {
 		context.Background(),
 		"/quicksilver.interchainstaking.v1.Query/ZoneInfos",
 		qBytes,
-		rpcclient.ABCIQueryOptions{Height: 0},
+		rpcclient.ABCIQueryOptions{Height: height},
 	)
+	if err == nil && !abciquery.Response.IsOK() {
+		err = fmt.Errorf("code %d: %s", abciquery.Response.Code, abciquery.Response.Log)
+	}
 	if err != nil {
-		s.Echo.Logger.Errorf("getZones: %v - %v", ErrABCIQuery, err)
-		return ErrABCIQuery
+		s.Echo.Logger.Errorf("queryZones: %v - %v", ErrABCIQuery, err)
+		return nil, 0, upstreamError(ErrABCIQuery, "quicksilver-rpc", err)
 	}
 
 	// decode query response
 	queryResponse := icstypes.QueryZonesInfoResponse{}
 	if err := marshaler.Unmarshal(abciquery.Response.Value, &queryResponse); err != nil {
-		s.Echo.Logger.Errorf("getZones: %v - %v", ErrUnmarshalResponse, err)
-		return ErrUnmarshalResponse
+		s.Echo.Logger.Errorf("queryZones: %v - %v", ErrUnmarshalResponse, err)
+		return nil, 0, upstreamError(ErrUnmarshalResponse, "quicksilver-rpc", err)
 	}
 
-	// encode response & cache
-	respdata, err := marshaler.MarshalJSON(&queryResponse)
+	return &queryResponse, abciquery.Response.Height, nil
+}
+
+func (s *Service) getAPR(key string) ([]byte, error) {
+	s.Echo.Logger.Infof("getAPR")
+
+	aprResp := s.queryAPR()
+
+	respdata, err := json.Marshal(aprResp)
 	if err != nil {
-		s.Echo.Logger.Errorf("getZones: %v - %v", ErrMarshalResponse, err)
-		return ErrMarshalResponse
+		s.Echo.Logger.Errorf("getAPR: %v - %v", ErrMarshalResponse, err)
+		return nil, ErrMarshalResponse
 	}
 
-	s.Cache.SetWithTTL(key, respdata, 1, 1*time.Minute)
+	s.setCached(key, respdata, time.Duration(s.Config.APRCacheTime)*time.Minute)
 
-	return ctx.JSONBlob(http.StatusOK, respdata)
+	return respdata, nil
 }
 
-func (s *Service) getAPR(ctx echov4.Context, key string) error {
-	s.Echo.Logger.Infof("getAPR")
-
+// queryAPR fetches the current APR of every configured chain concurrently.
+func (s *Service) queryAPR() APRResponse {
 	chains := s.Config.Chains
 	aprResp := APRResponse{}
 
+	feeRate, err := getCommissionRate(s.Cache, s.Config)
+	if err != nil {
+		s.Echo.Logger.Errorf("getAPR: %v - %v", ErrUnableToGetCommission, err)
+		feeRate = fallbackFeeRate(s.Config)
+	}
+
 	// WaitGroup to synchronize goroutines
 	var wg sync.WaitGroup
+	var mu sync.Mutex
 	wg.Add(len(s.Config.Chains))
 
 	for _, chain := range chains {
 		go func(chainname string) {
 			defer wg.Done()
-			chainAPR, err := getAPRquery(s.Cache, s.Config, chainname)
+			chainAPR, err := getAPRquery(s.Cache, s.Config, chainname, feeRate)
 			if err != nil {
 				s.Echo.Logger.Errorf("unable to retrieve apy for %s: %s", chainname, err.Error())
 			}
+			mu.Lock()
 			aprResp.Chains = append(aprResp.Chains, chainAPR)
+			mu.Unlock()
 		}(chain)
 	}
 	// Wait for goroutines to complete
 	wg.Wait()
 
-	respdata, err := json.Marshal(aprResp)
-	if err != nil {
-		s.Echo.Logger.Errorf("getAPR: %v - %v", ErrMarshalResponse, err)
-		return ErrMarshalResponse
-	}
-
-	s.Cache.SetWithTTL(key, respdata, 1, time.Duration(s.Config.APRCacheTime)*time.Minute)
-
-	return ctx.JSONBlob(http.StatusOK, respdata)
+	return aprResp
 }
 
-func (s *Service) getSupply(ctx echov4.Context, key string) error {
+func (s *Service) getSupply(key string) ([]byte, error) {
 	s.Echo.Logger.Infof("getSupply")
 
-	supply, _, err := getSupply(s.Config.SupplyLcdEndpoint + "/quicksilver/supply/v1/supply")
+	supply, _, height, err := getSupply(s.Config.SupplyLcdEndpoint + "/quicksilver/supply/v1/supply")
 	if err != nil {
 		s.Echo.Logger.Errorf("getCirculatingSupply: %v - %v", ErrUnableToGetTotalSupply, err)
-		return ErrUnableToGetTotalSupply
+		return nil, upstreamError(ErrUnableToGetTotalSupply, "quicksilver-lcd", err)
 	}
 
 	respData, err := json.Marshal(supply.Quo(sdkmath.NewInt(1_000_000)).Int64())
 	if err != nil {
 		s.Echo.Logger.Errorf("getCirculatingSupply: %v - %v", ErrMarshalResponse, err)
-		return ErrMarshalResponse
+		return nil, ErrMarshalResponse
 	}
-	s.Cache.SetWithTTL(key, respData, 1, time.Duration(s.Config.SupplyCacheTime)*time.Minute)
+	s.setCachedAt(key, respData, time.Duration(s.Config.SupplyCacheTime)*time.Minute, s.quicksilverMeta(height))
 
-	return ctx.JSONBlob(http.StatusOK, respData)
+	return respData, nil
 }
-func (s *Service) getCirculatingSupply(ctx echov4.Context, key string) error {
+
+func (s *Service) getCirculatingSupply(key string) ([]byte, error) {
 	s.Echo.Logger.Infof("getCirculatingSupply")
 
-	_, circulatingSupply, err := getSupply(s.Config.SupplyLcdEndpoint + "/quicksilver/supply/v1/supply")
+	_, circulatingSupply, height, err := getSupply(s.Config.SupplyLcdEndpoint + "/quicksilver/supply/v1/supply")
 	if err != nil {
 		s.Echo.Logger.Errorf("getCirculatingSupply: %v - %v", ErrUnableToGetTotalSupply, err)
-		return ErrUnableToGetTotalSupply
+		return nil, upstreamError(ErrUnableToGetTotalSupply, "quicksilver-lcd", err)
 	}
 
 	respData, err := json.Marshal(circulatingSupply.Quo(sdkmath.NewInt(1_000_000)).Int64())
 	if err != nil {
 		s.Echo.Logger.Errorf("getCirculatingSupply: %v - %v", ErrMarshalResponse, err)
-		return ErrMarshalResponse
+		return nil, ErrMarshalResponse
 	}
-	s.Cache.SetWithTTL(key, respData, 1, time.Duration(s.Config.SupplyCacheTime)*time.Minute)
+	s.setCachedAt(key, respData, time.Duration(s.Config.SupplyCacheTime)*time.Minute, s.quicksilverMeta(height))
 
-	return ctx.JSONBlob(http.StatusOK, respData)
+	return respData, nil
 }
 
-func (s *Service) getPrices(ctx echov4.Context) error {
+// getPrices returns market prices plus q-asset prices derived from their zones'
+// redemption rates, in the currencies of query. With provenance, the inputs
+// behind each derived price are returned alongside.
+func (s *Service) getPrices(key string, query PriceQuery) ([]byte, error) {
 	s.Echo.Logger.Infof("getPrices")
-	key := "prices"
-	data, found := s.Cache.Get(key)
-	if found {
-		return ctx.JSONBlob(http.StatusOK, data.([]byte))
-	} else {
-		// Create a new GET request
-		req, err := http.NewRequest("GET", "https://pro-api.coinmarketcap.com/v1/cryptocurrency/quotes/latest?slug="+strings.Join(s.Config.CMCSlugs, ","), nil)
-		if err != nil {
-			log.Fatalf("Failed to create request: %v", err)
-		}
-
-		// Set headers
-		req.Header.Add("X-CMC_PRO_API_KEY", os.Getenv("CMC_KEY"))
-
-		// Use a client to send the request
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Fatalf("Failed to send request: %v", err)
-		}
-		defer resp.Body.Close()
-
-		// Read all the response body
-		result, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatalf("failed to read response body: %v", err)
-		}
-
-		var cmcResponse CMCResponse
-		err = json.Unmarshal(result, &cmcResponse)
-		if err != nil {
-			log.Fatalf("failed to unmarshal response: %v", err)
-		}
 
-		priceOutput := PriceOutput{}
-		for _, data := range cmcResponse.Data {
-			priceOutput[data.Symbol] = data.Quote["USD"].Price
-		}
+	quotes, err := s.queryPrices(query.Currencies)
+	if err != nil {
+		s.Echo.Logger.Errorf("getPrices: %v - %v", ErrUnableToGetPrices, err)
+		return nil, err
+	}
 
-		respData, err := json.Marshal(priceOutput)
-		if err != nil {
-			log.Fatalf("failed to marshal response: %v", err)
+	derived := s.deriveQAssetPrices(quotes)
+	for symbol, byCurrency := range derived {
+		for currency, qAsset := range byCurrency {
+			quotes.set(symbol, currency, qAsset.Price)
 		}
-
-		s.Cache.SetWithTTL(key, respData, 1, 5*time.Minute)
-
-		return ctx.JSONBlob(http.StatusOK, respData)
+	}
+	if key == s.defaultPriceQuery().cacheKey() {
+		s.recordPrices(time.Now().UTC(), quotes.In(defaultPriceCurrency))
 	}
 
-}
-
-type PriceOutput map[string]float64
+	var prices, derivedPrices interface{} = quotes, derived
+	if !query.Nested {
+		prices = quotes.In(query.Currencies[0])
+		derivedPrices = derived.In(query.Currencies[0])
+	}
 
-type CMCStatus struct {
-	ErrorCode    int    `json:"error_code"`
-	ErrorMessage string `json:"error_message"`
-	Elapsed      int    `json:"elapsed"`
-	CreditCount  int    `json:"credit_count"`
-}
+	var resp interface{} = prices
+	if query.Provenance {
+		resp = PriceProvenanceResponse{Prices: prices, Derived: derivedPrices}
+	}
 
-type CMCResponse struct {
-	Status CMCStatus          `json:"status"`
-	Data   map[string]CMCData `json:"data"`
-}
+	respData, err := json.Marshal(resp)
+	if err != nil {
+		s.Echo.Logger.Errorf("getPrices: %v - %v", ErrMarshalResponse, err)
+		return nil, ErrMarshalResponse
+	}
 
-type CMCData struct {
-	Symbol string              `json:"symbol"`
-	Quote  map[string]CMCQuote `json:"quote"`
-}
+	s.setCached(key, respData, 5*time.Minute)
 
-type CMCQuote struct {
-	Price float64 `json:"price"`
+	return respData, nil
 }
 
 func (s *Service) getTopAccounts(ctx echov4.Context, pretty bool) error {
 	key := "top100"
-	var result []byte
-	data, found := s.Cache.Get(key)
-	if found {
-		result = data.([]byte)
-	} else {
-		resp, err := http.Get(s.Config.SupplyLcdEndpoint + "/quicksilver/supply/v1/topn/100")
-		if err != nil {
-			s.Logger.Error("unable to get top accounts", err)
-			return err
-		}
-
-		defer resp.Body.Close()
-
-		// Read all the response body
-		result, err = io.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatalf("failed to read response body: %v", err)
-		}
-
-		s.Logger.Info("set cache for top accounts")
-		s.Cache.SetWithTTL(key, result, 1, 1*time.Hour)
+	result, err := s.cached(ctx, key, func() ([]byte, error) {
+		return s.getTopAccountsData(key)
+	})
+	if err != nil {
+		return err
 	}
 
 	if !pretty {
@@ -516,7 +531,7 @@ func (s *Service) getTopAccounts(ctx echov4.Context, pretty bool) error {
 	}
 
 	var accountResponse AccountResponse
-	err := json.Unmarshal(result, &accountResponse)
+	err = json.Unmarshal(result, &accountResponse)
 	if err != nil {
 		return err
 	}
@@ -528,6 +543,28 @@ func (s *Service) getTopAccounts(ctx echov4.Context, pretty bool) error {
 	return ctx.HTML(http.StatusOK, htmlContent)
 }
 
+func (s *Service) getTopAccountsData(key string) ([]byte, error) {
+	resp, err := http.Get(s.Config.SupplyLcdEndpoint + "/quicksilver/supply/v1/topn/100")
+	if err != nil {
+		s.Echo.Logger.Errorf("getTopAccounts: %v - %v", ErrUnableToGetTopAccounts, err)
+		return nil, upstreamError(ErrUnableToGetTopAccounts, "quicksilver-lcd", err)
+	}
+
+	defer resp.Body.Close()
+
+	// Read all the response body
+	result, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.Echo.Logger.Errorf("getTopAccounts: %v - %v", ErrUnableToGetTopAccounts, err)
+		return nil, upstreamError(ErrUnableToGetTopAccounts, "quicksilver-lcd", err)
+	}
+
+	s.Logger.Info("set cache for top accounts")
+	s.setCached(key, result, 1*time.Hour)
+
+	return result, nil
+}
+
 type AccountResponse struct {
 	Accounts []TopAccount `json:"accounts"`
 }
@@ -542,26 +579,30 @@ type Supply struct {
 	CirculatingSupply sdkmath.Int `json:"circulating_supply"`
 }
 
-func getSupply(url string) (sdkmath.Int, sdkmath.Int, error) {
+// getSupply returns the total and circulating supply, and the height the LCD
+// read them at, or zero if it did not say.
+func getSupply(url string) (sdkmath.Int, sdkmath.Int, int64, error) {
 	resp, err := http.Get(url)
 	if err != nil {
-		return sdkmath.Int{}, sdkmath.Int{}, err
+		return sdkmath.Int{}, sdkmath.Int{}, 0, err
 	}
+	defer resp.Body.Close()
 
 	var result json.RawMessage
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
-		return sdkmath.Int{}, sdkmath.Int{}, err
+		return sdkmath.Int{}, sdkmath.Int{}, 0, err
 	}
 
 	var supply Supply
 	err = json.Unmarshal(result, &supply)
 	if err != nil {
-		return sdkmath.Int{}, sdkmath.Int{}, err
+		return sdkmath.Int{}, sdkmath.Int{}, 0, err
 	}
 
-	return supply.Supply, supply.CirculatingSupply, nil
+	height, _ := strconv.ParseInt(resp.Header.Get(HeaderCosmosBlockHeight), 10, 64)
 
+	return supply.Supply, supply.CirculatingSupply, height, nil
 }
 
 func (s *Service) getLogo(ctx echov4.Context, key string, chain string, address string, height int, width int) ([]byte, error) {
@@ -585,7 +626,7 @@ func (s *Service) getLogo(ctx echov4.Context, key string, chain string, address
 
 	ctx.Logger().Error(fmt.Sprintf("read %d bytes", len(out.Bytes())))
 
-	s.Cache.SetWithTTL(key, out.Bytes(), 1, 12*time.Hour)
+	s.Cache.SetWithTTL(key, out.Bytes(), 12*time.Hour)
 
 	return out.Bytes(), nil
 }
@@ -595,32 +636,96 @@ func (s *Service) placeHolder(key string, height int, width int) []byte {
 	img = imaging.Resize(img, height, width, imaging.Lanczos)
 	result := bytes.NewBuffer([]byte{})
 	imaging.Encode(result, img, imaging.PNG, imaging.PNGCompressionLevel(png.BestCompression))
-	s.Cache.SetWithTTL(key, result.Bytes(), 1, 1*time.Hour)
+	s.Cache.SetWithTTL(key, result.Bytes(), 1*time.Hour)
 	return result.Bytes()
 }
 
-func (s *Service) doDefi(ctx echov4.Context) ([]DefiInfo, error) {
+func (s *Service) getDefi(key string) ([]byte, error) {
+	s.Echo.Logger.Infof("getDefi")
+
+	defi, err := s.doDefi()
+	if err != nil {
+		return nil, err
+	}
+
+	respdata, err := json.Marshal(defi)
+	if err != nil {
+		s.Echo.Logger.Errorf("getDefi: %v - %v", ErrMarshalResponse, err)
+		return nil, ErrMarshalResponse
+	}
+
+	// entries are assembled from the per-pool cache, so this only bounds how long
+	// a refreshed pool takes to show up
+	s.setCached(key, respdata, 5*time.Minute)
+
+	return respdata, nil
+}
+
+// refreshDefi re-queries every defi provider in use and rebuilds the per-pool
+// entries derived from them. Pools of a provider that fails keep their cached values.
+func (s *Service) refreshDefi(key string) ([]byte, error) {
+	queries := map[string]func() error{
+		"ux": func() error {
+			_, err := s.queryUx()
+			return err
+		},
+		"osmosis": func() error {
+			_, err := s.queryOsmo()
+			return err
+		},
+		"shade": func() error {
+			_, err := s.queryShade()
+			return err
+		},
+	}
+
+	refreshed := map[string]bool{}
+	for _, d := range s.Config.DefiInfo {
+		query, ok := queries[d.Provider]
+		if !ok {
+			continue
+		}
+		if _, done := refreshed[d.Provider]; !done {
+			err := query()
+			if err != nil {
+				s.Echo.Logger.Errorf("refreshDefi: unable to query %s - %v", d.Provider, err)
+			}
+			refreshed[d.Provider] = err == nil
+		}
+		if refreshed[d.Provider] {
+			s.Cache.Delete(defiKey(d))
+		}
+	}
+
+	return s.getDefi(key)
+}
+
+func defiKey(d DefiInfo) string {
+	return fmt.Sprintf("defi.%s.%s", d.Provider, d.Id)
+}
+
+func (s *Service) doDefi() ([]DefiInfo, error) {
 	out := []DefiInfo{}
 	for _, d := range s.Config.DefiInfo {
 		switch d.Provider {
 		case "ux":
 			r, err := s.doDefiUx(d)
 			if err != nil {
-				ctx.Logger().Error("unable to fetch ux defi", err)
+				s.Echo.Logger.Error("unable to fetch ux defi", err)
 				out = append(out, d)
 			}
 			out = append(out, r)
 		case "osmosis":
 			r, err := s.doDefiOsmosis(d)
 			if err != nil {
-				ctx.Logger().Error("unable to fetch osmosis defi", err)
+				s.Echo.Logger.Error("unable to fetch osmosis defi", err)
 				out = append(out, d)
 			}
 			out = append(out, r)
 		case "shade":
 			r, err := s.doDefiShade(d)
 			if err != nil {
-				ctx.Logger().Error("unable to fetch shade defi", err)
+				s.Echo.Logger.Error("unable to fetch shade defi", err)
 				out = append(out, d)
 			}
 			out = append(out, r)
@@ -639,7 +744,7 @@ type UxResult struct {
 }
 
 func (s *Service) doDefiUx(d DefiInfo) (DefiInfo, error) {
-	key := fmt.Sprintf("defi.%s.%s", d.Provider, d.Id)
+	key := defiKey(d)
 	cached, found := s.Cache.Get(key)
 	if found {
 		s.Logger.Info(fmt.Sprintf("hit cache for ux pool %s", d.Id))
@@ -664,7 +769,7 @@ func (s *Service) doDefiUx(d DefiInfo) (DefiInfo, error) {
 			break
 		}
 	}
-	s.Cache.SetWithTTL(key, d, 1, 3*time.Hour)
+	s.Cache.SetWithTTL(key, d, 3*time.Hour)
 
 	return d, nil
 }
@@ -684,7 +789,7 @@ type OsmosisPoolAprResult struct {
 }
 
 func (s *Service) doDefiOsmosis(d DefiInfo) (DefiInfo, error) {
-	key := fmt.Sprintf("defi.%s.%s", d.Provider, d.Id)
+	key := defiKey(d)
 	cached, found := s.Cache.Get(key)
 	if found {
 		s.Logger.Info(fmt.Sprintf("hit cache for osmosis pool %s", d.Id))
@@ -717,7 +822,7 @@ func (s *Service) doDefiOsmosis(d DefiInfo) (DefiInfo, error) {
 		}
 	}
 
-	s.Cache.SetWithTTL(key, d, 1, 3*time.Hour)
+	s.Cache.SetWithTTL(key, d, 3*time.Hour)
 
 	return d, nil
 }
@@ -732,7 +837,7 @@ type ShadeResult struct {
 }
 
 func (s *Service) doDefiShade(d DefiInfo) (DefiInfo, error) {
-	key := fmt.Sprintf("defi.%s.%s", d.Provider, d.Id)
+	key := defiKey(d)
 	cached, found := s.Cache.Get(key)
 	if found {
 		s.Logger.Info(fmt.Sprintf("hit cache for shade pool %s", d.Id))
@@ -759,7 +864,7 @@ func (s *Service) doDefiShade(d DefiInfo) (DefiInfo, error) {
 			break
 		}
 	}
-	s.Cache.SetWithTTL(key, d, 1, 3*time.Hour)
+	s.Cache.SetWithTTL(key, d, 3*time.Hour)
 	return d, nil
 }
 
@@ -777,7 +882,7 @@ func (s *Service) queryShade() ([]ShadeResult, error) {
 	if err != nil {
 		return nil, err
 	}
-	s.Cache.SetWithTTL("defi.raw.shade", result, 1, 3*time.Hour)
+	s.Cache.SetWithTTL("defi.raw.shade", result, 3*time.Hour)
 	time.Sleep(time.Millisecond * 200)
 
 	return result, nil
@@ -797,7 +902,7 @@ func (s *Service) queryUx() ([]UxResult, error) {
 	if err != nil {
 		return nil, err
 	}
-	s.Cache.SetWithTTL("defi.raw.ux", result, 1, 3*time.Hour)
+	s.Cache.SetWithTTL("defi.raw.ux", result, 3*time.Hour)
 	time.Sleep(time.Millisecond * 200)
 
 	return result, nil
@@ -833,7 +938,7 @@ func (s *Service) queryOsmo() (OsmosisPoolCacheResult, error) {
 	}
 	fmt.Printf("poolResult.PoolAprs: %v\n", poolResult.PoolAprs)
 
-	s.Cache.SetWithTTL("defi.raw.osmosis", poolResult, 1, 3*time.Hour)
+	s.Cache.SetWithTTL("defi.raw.osmosis", poolResult, 3*time.Hour)
 	time.Sleep(time.Millisecond * 200)
 
 	return poolResult, nil
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	echov4 "github.com/labstack/echo/v4"
+	tmhttp "github.com/tendermint/tendermint/rpc/client/http"
+)
+
+const metaKeyPrefix = "meta."
+
+// parseHeight reads the optional height parameter. Zero means the latest height.
+func parseHeight(ctx echov4.Context) (int64, error) {
+	raw := ctx.QueryParam("height")
+	if raw == "" {
+		return 0, nil
+	}
+
+	height, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || height <= 0 {
+		return 0, fmt.Errorf("invalid height %q: expected a positive block height", raw)
+	}
+	return height, nil
+}
+
+// heightKey is the cache key of a response pinned to height.
+func heightKey(key string, height int64) string {
+	if height == 0 {
+		return key
+	}
+	return fmt.Sprintf("%s@%d", key, height)
+}
+
+// checkHeight rejects heights above the latest block of the chain client is
+// connected to, before any query is made at them. Zero is always accepted.
+func checkHeight(client *tmhttp.HTTP, height int64, upstream string) error {
+	if height == 0 {
+		return nil
+	}
+
+	status, err := client.Status(context.Background())
+	if err != nil {
+		return upstreamError(ErrRPCClientConnection, upstream, err)
+	}
+	if latest := status.SyncInfo.LatestBlockHeight; height > latest {
+		return invalidParameter(fmt.Errorf("height %d is above the latest block %d", height, latest))
+	}
+	return nil
+}
+
+// setCachedAtHeight caches a response read at height. Latest height responses
+// are cached for ttl like any other; responses pinned to a past height never
+// change, so they are cached indefinitely, left to cost eviction, and need no
+// last-known-good copy. Only their chain state is recorded, under the meta key.
+func (s *Service) setCachedAtHeight(key string, data []byte, height int64, ttl time.Duration, meta ChainMeta) {
+	if height == 0 {
+		s.setCachedAt(key, data, ttl, meta)
+		return
+	}
+
+	s.Cache.SetWithTTL(key, data, 0)
+	s.Cache.SetWithTTL(metaKeyPrefix+key, cachedResponse{
+		ChainMeta: meta,
+		FetchedAt: time.Now(),
+	}, 0)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	echov4 "github.com/labstack/echo/v4"
+	bolt "go.etcd.io/bbolt"
+)
+
+// HistoryStore is an append-only time series store backed by BoltDB. Each series
+// lives in its own bucket, keyed by big-endian unix nanoseconds so that cursor
+// order is time order.
+type HistoryStore struct {
+	db *bolt.DB
+}
+
+func NewHistoryStore(path string) (*HistoryStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryStore{db: db}, nil
+}
+
+func (h *HistoryStore) Close() error {
+	return h.db.Close()
+}
+
+// Record stores value in series at time t, replacing any sample with the same timestamp.
+func (h *HistoryStore) Record(series string, t time.Time, value []byte) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(series))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(historyKey(t), value)
+	})
+}
+
+// Range calls fn for every sample in series with from <= t <= to, oldest first.
+// The value passed to fn is only valid for the duration of the call.
+func (h *HistoryStore) Range(series string, from time.Time, to time.Time, fn func(t time.Time, value []byte) error) error {
+	return h.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(series))
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		max := historyKey(to)
+		for k, v := c.Seek(historyKey(from)); k != nil && bytes.Compare(k, max) <= 0; k, v = c.Next() {
+			if err := fn(time.Unix(0, int64(binary.BigEndian.Uint64(k))).UTC(), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func historyKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+// parseHistoryRange reads the from, to and interval query parameters shared by
+// the history endpoints. from and to accept unix seconds or RFC3339 and default
+// to the last seven days; interval is a Go duration, zero meaning raw samples.
+func parseHistoryRange(ctx echov4.Context) (time.Time, time.Time, time.Duration, error) {
+	to, err := parseHistoryTime(ctx.QueryParam("to"), time.Now().UTC())
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("invalid to parameter: %w", err)
+	}
+	from, err := parseHistoryTime(ctx.QueryParam("from"), to.Add(-7*24*time.Hour))
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("invalid from parameter: %w", err)
+	}
+	if from.After(to) {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("from must not be after to")
+	}
+
+	var interval time.Duration
+	if raw := ctx.QueryParam("interval"); raw != "" {
+		interval, err = time.ParseDuration(raw)
+		if err != nil || interval < 0 {
+			return time.Time{}, time.Time{}, 0, fmt.Errorf("invalid interval parameter: %s", raw)
+		}
+	}
+
+	return from, to, interval, nil
+}
+
+func parseHistoryTime(raw string, def time.Time) (time.Time, error) {
+	if raw == "" {
+		return def, nil
+	}
+	if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(unix, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+// rangeHistory reads the samples of series in the range requested by ctx,
+// calling sample with each one and the start of the interval bucket it falls
+// into, oldest first.
+func (s *Service) rangeHistory(ctx echov4.Context, series string, sample func(bucket time.Time, value []byte) error) error {
+	if s.History == nil {
+		return ErrHistoryDisabled
+	}
+
+	from, to, interval, err := parseHistoryRange(ctx)
+	if err != nil {
+		return invalidParameter(err)
+	}
+
+	err = s.History.Range(series, from, to, func(t time.Time, value []byte) error {
+		if interval > 0 {
+			t = t.Truncate(interval)
+		}
+		return sample(t, value)
+	})
+	if err != nil {
+		s.Echo.Logger.Errorf("rangeHistory: %v - %v", ErrUnmarshalResponse, err)
+		return ErrUnmarshalResponse
+	}
+	return nil
+}
+
+// historyCSVRow formats a bucket of samples as a CSV row: its time, values and
+// sample count.
+func historyCSVRow(t time.Time, samples int, values ...float64) []string {
+	row := []string{t.Format(time.RFC3339)}
+	for _, value := range values {
+		row = append(row, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	return append(row, strconv.Itoa(samples))
+}
+
+// writeHistoryCSV responds with header and rows as a CSV attachment named
+// filename.
+func writeHistoryCSV(ctx echov4.Context, filename string, header []string, rows [][]string) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(header)
+	for _, row := range rows {
+		_ = w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	ctx.Response().Header().Set(echov4.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return ctx.Blob(http.StatusOK, "text/csv", buf.Bytes())
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/dgraph-io/ristretto"
@@ -56,20 +57,104 @@ func main() {
 		return
 	}
 
-	// risteretto cache
-	ristrettoCache, err := ristretto.NewCache(&ristretto.Config{
-		NumCounters: 1e7,     // Num keys to track frequency of (10M).
-		MaxCost:     1 << 30, // Maximum cost of cache (1GB).
-		BufferItems: 64,      // Number of keys per Get buffer.
-	})
+	if !validPricePolicy(cfg.Prices.Policy) {
+		e.Logger.Fatalf("%v: %s", ErrUnknownPricePolicy, cfg.Prices.Policy)
+	}
+
+	var cache Cache
+	switch cfg.Cache.Backend {
+	case "", "ristretto":
+		// risteretto cache; entries cost their size in bytes
+		numCounters := cfg.Cache.NumCounters
+		if numCounters <= 0 {
+			numCounters = 1e7 // Num keys to track frequency of (10M).
+		}
+		maxCost := cfg.Cache.MaxCostBytes
+		if maxCost <= 0 {
+			maxCost = 1 << 30 // Maximum cost of cache (1GB).
+		}
+		ristrettoCache, err := NewRistrettoCache(&ristretto.Config{
+			NumCounters: numCounters,
+			MaxCost:     maxCost,
+			BufferItems: 64, // Number of keys per Get buffer.
+			Metrics:     true,
+		})
+		if err != nil {
+			e.Logger.Fatalf("unable to start risteretto cache: %v", err)
+		}
+		registerRistrettoMetrics(ristrettoCache.Metrics)
+		cache = ristrettoCache
+	case "redis":
+		// shared redis cache
+		redisCache, err := NewRedisCache(cfg.Cache.Redis)
+		if err != nil {
+			e.Logger.Fatalf("unable to connect to redis cache: %v", err)
+		}
+		defer redisCache.Close()
+		cache = redisCache
+	default:
+		e.Logger.Fatalf("%v: %s", ErrUnknownCacheBackend, cfg.Cache.Backend)
+	}
+
+	// reload the previous cache snapshot
+	if snapshotter, ok := cache.(Snapshotter); ok && cfg.Snapshot.Path != "" {
+		restored, err := snapshotter.LoadSnapshot(cfg.Snapshot.Path)
+		if err != nil {
+			e.Logger.Errorf("%v: %v", ErrLoadSnapshot, err)
+		} else {
+			e.Logger.Infof("restored %d cache entries from %s", restored, cfg.Snapshot.Path)
+		}
+	}
+
+	// chains served by the per-chain endpoints
+	registry, err := NewChainRegistry(cfg)
 	if err != nil {
-		e.Logger.Fatalf("unable to start risteretto cache: %v", err)
+		e.Logger.Fatalf("%v: %v", ErrInvalidChainRegistry, err)
+	}
+	registered := map[string]bool{}
+	for _, chain := range registry.Chains() {
+		registered[chain.Name] = true
+	}
+	for _, name := range cfg.Chains {
+		if !registered[name] {
+			e.Logger.Warnf("zone %s is not in chain_registry; its per-chain endpoints will return unknown chain", name)
+		}
 	}
 
 	// quick cache service
-	service := NewCacheService(e, ristrettoCache, cfg)
+	service := NewCacheService(e, cache, cfg)
+	service.Registry = registry
+
+	// historical time series store; opened before the background jobs that
+	// record into it
+	if cfg.HistoryPath != "" {
+		history, err := NewHistoryStore(cfg.HistoryPath)
+		if err != nil {
+			e.Logger.Fatalf("%v: %v", ErrOpenHistoryStore, err)
+		}
+		defer history.Close()
+		service.History = history
+	}
+
+	// background jobs are stopped on shutdown
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// pre-warm cached endpoints
+	service.startRefresher(bgCtx)
+
+	// periodic cache snapshots
+	if cfg.Snapshot.Path != "" && cfg.Snapshot.IntervalMinutes > 0 {
+		go service.snapshotCache(bgCtx, time.Duration(cfg.Snapshot.IntervalMinutes)*time.Minute)
+	}
+
+	// periodic apr samples
+	if service.History != nil && cfg.APRSampleTime > 0 {
+		go service.sampleAPR(bgCtx, time.Duration(cfg.APRSampleTime)*time.Minute)
+	}
 
 	// routing (see routes.go)
+	e.HTTPErrorHandler = service.HTTPErrorHandler
 	service.ConfigureRoutes()
 
 	// start server
@@ -83,16 +168,22 @@ func main() {
 		}
 	}()
 
-	// Wait for interrupt signal to gracefully shutdown the server. Use a timeout
-	// of 30 seconds. Use a buffered channel to avoid missing signals as
-	// recommended for signal.Notify.
+	// Wait for an interrupt, or the SIGTERM Kubernetes stops pods with, to
+	// gracefully shutdown the server. Use a timeout of 30 seconds. Use a
+	// buffered channel to avoid missing signals as recommended for signal.Notify.
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
+	stopBackground()
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	if err := e.Shutdown(ctx); err != nil {
-		e.Logger.Fatal(err)
+		// still save the snapshot below; exiting here would lose it
+		e.Logger.Errorf("%v: %v", ErrEchoFatal, err)
+	}
+
+	if cfg.Snapshot.Path != "" {
+		service.saveSnapshot()
 	}
 
 	fmt.Println("...server shutdown.")
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// cacheLookups counts endpoint cache lookups by key family and outcome: hit,
+// miss (this request fetched upstream), coalesced (waited on another request's
+// fetch) or stale (served a last-known-good copy).
+var cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "evince",
+	Subsystem: "cache",
+	Name:      "lookups_total",
+	Help:      "Endpoint cache lookups by key family and result.",
+}, []string{"key", "result"})
+
+// metricKey reduces a cache key to its family, e.g. validatorList.cosmoshub-4
+// and zones@1234 become validatorList and zones, to keep label cardinality
+// bounded.
+func metricKey(key string) string {
+	key, _, _ = strings.Cut(key, "@")
+	family, _, _ := strings.Cut(key, ".")
+	return family
+}
+
+// registerRistrettoMetrics exports ristretto's internal counters. Costs are in bytes.
+func registerRistrettoMetrics(metrics *ristretto.Metrics) {
+	counters := map[string]struct {
+		help  string
+		value func() uint64
+	}{
+		"hits_total":               {"Ristretto cache hits.", metrics.Hits},
+		"misses_total":             {"Ristretto cache misses.", metrics.Misses},
+		"keys_added_total":         {"Keys added to the ristretto cache.", metrics.KeysAdded},
+		"keys_updated_total":       {"Keys updated in the ristretto cache.", metrics.KeysUpdated},
+		"keys_evicted_total":       {"Keys evicted from the ristretto cache.", metrics.KeysEvicted},
+		"cost_added_bytes_total":   {"Bytes of values added to the ristretto cache.", metrics.CostAdded},
+		"cost_evicted_bytes_total": {"Bytes of values evicted from the ristretto cache.", metrics.CostEvicted},
+		"sets_dropped_total":       {"Sets dropped by ristretto due to contention.", metrics.SetsDropped},
+		"sets_rejected_total":      {"Sets rejected by the ristretto admission policy.", metrics.SetsRejected},
+	}
+
+	for name, counter := range counters {
+		value := counter.value
+		promauto.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "evince",
+			Subsystem: "ristretto",
+			Name:      name,
+			Help:      counter.help,
+		}, func() float64 { return float64(value()) })
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	"github.com/cosmos/cosmos-sdk/codec"
+	cdctypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	"github.com/cosmos/cosmos-sdk/types/query"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	icstypes "github.com/ingenuity-build/quicksilver/x/interchainstaking/types"
+	echov4 "github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	tmhttp "github.com/tendermint/tendermint/rpc/client/http"
+	"golang.org/x/time/rate"
+)
+
+// PortfolioConfig bounds the cost of /portfolio. Concurrency caps how many
+// chains a single portfolio queries at once. RatePerMinute and Burst limit the
+// requests of each client IP.
+type PortfolioConfig struct {
+	Concurrency   int     `yaml:"concurrency" json:"concurrency"`
+	RatePerMinute float64 `yaml:"rate_per_minute" json:"rate_per_minute"`
+	Burst         int     `yaml:"burst" json:"burst"`
+}
+
+const (
+	defaultPortfolioConcurrency   = 4
+	defaultPortfolioRatePerMinute = 30
+	defaultPortfolioBurst         = 10
+)
+
+// PortfolioAsset is an amount of denom in base units. Symbol and USD are only
+// set for denoms evince can price.
+type PortfolioAsset struct {
+	Denom  string      `json:"denom"`
+	Symbol string      `json:"symbol,omitempty"`
+	Amount sdkmath.Int `json:"amount"`
+	USD    float64     `json:"usd"`
+}
+
+type PortfolioDelegation struct {
+	ValidatorAddress string `json:"validator_address"`
+	PortfolioAsset
+}
+
+// ChainPortfolio holds an account's assets on one chain. q-assets held on
+// Quicksilver are listed separately from its other balances. A chain that
+// could not be queried carries an error and no assets.
+type ChainPortfolio struct {
+	ChainID     string                `json:"chain_id"`
+	Address     string                `json:"address"`
+	Balances    []PortfolioAsset      `json:"balances"`
+	QAssets     []PortfolioAsset      `json:"q_assets"`
+	Delegations []PortfolioDelegation `json:"delegations"`
+	TotalUSD    float64               `json:"total_usd"`
+	Error       string                `json:"error,omitempty"`
+}
+
+type PortfolioResponse struct {
+	Chains     []ChainPortfolio `json:"chains"`
+	TotalUSD   float64          `json:"total_usd"`
+	PriceError string           `json:"price_error,omitempty"`
+}
+
+// portfolioDenom describes how a denom is priced.
+type portfolioDenom struct {
+	Symbol   string
+	Decimals int
+	QAsset   bool
+}
+
+// portfolioRateLimiter limits /portfolio requests per client IP. Cached
+// portfolios count too, as every distinct address costs a full fan-out.
+func (s *Service) portfolioRateLimiter() echov4.MiddlewareFunc {
+	perMinute := s.Config.Portfolio.RatePerMinute
+	if perMinute <= 0 {
+		perMinute = defaultPortfolioRatePerMinute
+	}
+	burst := s.Config.Portfolio.Burst
+	if burst <= 0 {
+		burst = defaultPortfolioBurst
+	}
+
+	return middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+		Store: middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+			Rate:      rate.Limit(perMinute / 60),
+			Burst:     burst,
+			ExpiresIn: 10 * time.Minute,
+		}),
+		DenyHandler: func(_ echov4.Context, _ string, _ error) error {
+			return ErrRateLimited
+		},
+	})
+}
+
+// getPortfolio collects the balances and delegations of account on every
+// registered chain, a few chains at a time, and values them with the /prices
+// data.
+func (s *Service) getPortfolio(key string, account []byte) ([]byte, error) {
+	s.Echo.Logger.Infof("getPortfolio")
+
+	prices, err := s.usdPrices()
+	resp := PortfolioResponse{Chains: []ChainPortfolio{}}
+	if err != nil {
+		s.Echo.Logger.Warnf("getPortfolio: portfolio will not be valued - %v", err)
+		resp.PriceError = err.Error()
+	}
+
+	denoms := s.portfolioDenoms()
+	chains := s.Registry.Chains()
+	portfolios := make([]ChainPortfolio, len(chains))
+
+	concurrency := s.Config.Portfolio.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultPortfolioConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	wg.Add(len(chains))
+	for i, chain := range chains {
+		go func(i int, chain ChainConfig) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			portfolios[i] = s.chainPortfolio(chain, account, denoms, prices)
+		}(i, chain)
+	}
+	wg.Wait()
+
+	for _, portfolio := range portfolios {
+		resp.Chains = append(resp.Chains, portfolio)
+		resp.TotalUSD += portfolio.TotalUSD
+	}
+
+	respdata, err := json.Marshal(resp)
+	if err != nil {
+		s.Echo.Logger.Errorf("getPortfolio: %v - %v", ErrMarshalResponse, err)
+		return nil, ErrMarshalResponse
+	}
+
+	s.setCached(key, respdata, 2*time.Minute)
+
+	return respdata, nil
+}
+
+// portfolioDenoms maps the base denom of every registered chain and the local
+// denom of every zone's q-asset to the symbol it is priced by.
+func (s *Service) portfolioDenoms() map[string]portfolioDenom {
+	denoms := map[string]portfolioDenom{}
+	decimals := map[string]int{}
+	for _, chain := range s.Registry.Chains() {
+		if chain.BaseDenom != "" {
+			denoms[chain.BaseDenom] = portfolioDenom{Symbol: s.denomSymbol(chain.BaseDenom), Decimals: chain.Decimals}
+		}
+		decimals[chain.ChainID] = chain.Decimals
+	}
+
+	// share the zones cached for /zones rather than querying them per portfolio
+	data, err := s.cachedData("zones", func() ([]byte, error) { return s.getZones("zones", 0) })
+	if err != nil {
+		s.Echo.Logger.Warnf("getPortfolio: q-assets will not be valued - %v", err)
+		return denoms
+	}
+	interfaceRegistry := cdctypes.NewInterfaceRegistry()
+	icstypes.RegisterInterfaces(interfaceRegistry)
+	marshaler := codec.NewProtoCodec(interfaceRegistry)
+	zones := icstypes.QueryZonesInfoResponse{}
+	if err := marshaler.UnmarshalJSON(data, &zones); err != nil {
+		s.Echo.Logger.Warnf("getPortfolio: q-assets will not be valued - %v - %v", ErrUnmarshalResponse, err)
+		return denoms
+	}
+	for _, zone := range zones.Zones {
+		zoneDecimals, ok := decimals[zone.ChainId]
+		if !ok {
+			zoneDecimals = defaultChainDecimals
+		}
+		denoms[zone.LocalDenom] = portfolioDenom{Symbol: "q" + s.denomSymbol(zone.BaseDenom), Decimals: zoneDecimals, QAsset: true}
+	}
+	return denoms
+}
+
+// usdPrices returns the USD prices served by /prices, falling back to the
+// last-known-good copy when they cannot be fetched.
+func (s *Service) usdPrices() (PriceOutput, error) {
+	query := PriceQuery{Currencies: []string{defaultPriceCurrency}}
+	if def := s.defaultPriceQuery(); def.Nested || def.Currencies[0] != defaultPriceCurrency {
+		query.Convert, query.Nested = true, true
+	}
+	key := query.cacheKey()
+
+	data, err := s.cachedData(key, func() ([]byte, error) { return s.getPrices(key, query) })
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Nested {
+		var quotes PriceQuotes
+		if err := json.Unmarshal(data, &quotes); err != nil {
+			return nil, err
+		}
+		return quotes.In(defaultPriceCurrency), nil
+	}
+
+	var prices PriceOutput
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+func (s *Service) chainPortfolio(chain ChainConfig, account []byte, denoms map[string]portfolioDenom, prices PriceOutput) ChainPortfolio {
+	portfolio := ChainPortfolio{
+		ChainID:     chain.ChainID,
+		Balances:    []PortfolioAsset{},
+		QAssets:     []PortfolioAsset{},
+		Delegations: []PortfolioDelegation{},
+	}
+
+	address, err := bech32.ConvertAndEncode(chain.Bech32Prefix, account)
+	if err != nil {
+		portfolio.Error = err.Error()
+		return portfolio
+	}
+	portfolio.Address = address
+
+	client, err := NewRPCClient(chain.RPC, 30*time.Second)
+	if err != nil {
+		s.Echo.Logger.Errorf("getPortfolio: %v - %v", ErrRPCClientConnection, err)
+		portfolio.Error = ErrRPCClientConnection.Error()
+		return portfolio
+	}
+
+	interfaceRegistry := cdctypes.NewInterfaceRegistry()
+	stakingtypes.RegisterInterfaces(interfaceRegistry)
+	marshaler := codec.NewProtoCodec(interfaceRegistry)
+
+	balances, err := queryBalances(client, marshaler, address, 0)
+	if err != nil {
+		s.Echo.Logger.Errorf("getPortfolio: %s balances - %v - %v", chain.ChainID, ErrABCIQuery, err)
+		portfolio.Error = ErrABCIQuery.Error()
+		return portfolio
+	}
+	delegations, _, err := queryDelegations(client, marshaler, address, 0)
+	if err != nil {
+		s.Echo.Logger.Errorf("getPortfolio: %s delegations - %v - %v", chain.ChainID, ErrABCIQuery, err)
+		portfolio.Error = ErrABCIQuery.Error()
+		return portfolio
+	}
+
+	for _, balance := range balances {
+		asset, denom := valueAsset(balance.Denom, balance.Amount, denoms, prices)
+		if denom.QAsset {
+			portfolio.QAssets = append(portfolio.QAssets, asset)
+		} else {
+			portfolio.Balances = append(portfolio.Balances, asset)
+		}
+		portfolio.TotalUSD += asset.USD
+	}
+	for _, delegation := range delegations {
+		asset, _ := valueAsset(delegation.Balance.Denom, delegation.Balance.Amount, denoms, prices)
+		portfolio.Delegations = append(portfolio.Delegations, PortfolioDelegation{
+			ValidatorAddress: delegation.Delegation.ValidatorAddress,
+			PortfolioAsset:   asset,
+		})
+		portfolio.TotalUSD += asset.USD
+	}
+	sort.Slice(portfolio.Delegations, func(i, j int) bool {
+		return portfolio.Delegations[i].Amount.GT(portfolio.Delegations[j].Amount)
+	})
+
+	return portfolio
+}
+
+// valueAsset prices amount of denom in USD when both the denom and its price are known.
+func valueAsset(denom string, amount sdkmath.Int, denoms map[string]portfolioDenom, prices PriceOutput) (PortfolioAsset, portfolioDenom) {
+	asset := PortfolioAsset{Denom: denom, Amount: amount}
+
+	info, ok := denoms[denom]
+	if !ok {
+		return asset, info
+	}
+	asset.Symbol = info.Symbol
+
+	if price, ok := prices[info.Symbol]; ok {
+		whole, _ := new(big.Float).Quo(new(big.Float).SetInt(amount.BigInt()), big.NewFloat(math.Pow10(info.Decimals))).Float64()
+		asset.USD = whole * price
+	}
+	return asset, info
+}
+
+// queryBalances returns every bank balance of address at height.
+func queryBalances(client *tmhttp.HTTP, marshaler *codec.ProtoCodec, address string, height int64) (sdk.Coins, error) {
+	balances := sdk.Coins{}
+	_, err := queryAllPages(client, "/cosmos.bank.v1beta1.Query/AllBalances", height,
+		func(page *query.PageRequest) []byte {
+			return marshaler.MustMarshal(&banktypes.QueryAllBalancesRequest{Address: address, Pagination: page})
+		},
+		func(value []byte) (*query.PageResponse, error) {
+			pageResponse := banktypes.QueryAllBalancesResponse{}
+			if err := marshaler.Unmarshal(value, &pageResponse); err != nil {
+				return nil, err
+			}
+			balances = append(balances, pageResponse.Balances...)
+			return pageResponse.Pagination, nil
+		},
+	)
+	return balances, err
+}
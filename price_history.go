@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	echov4 "github.com/labstack/echo/v4"
+)
+
+// PriceCandle summarises the prices sampled within one interval bucket.
+type PriceCandle struct {
+	Time    time.Time `json:"time"`
+	Open    float64   `json:"open"`
+	High    float64   `json:"high"`
+	Low     float64   `json:"low"`
+	Close   float64   `json:"close"`
+	Samples int       `json:"samples"`
+}
+
+type PriceHistoryResponse struct {
+	Symbol  string        `json:"symbol"`
+	Candles []PriceCandle `json:"candles"`
+}
+
+func priceHistorySeries(symbol string) string {
+	return "price/" + symbol
+}
+
+// recordPrices stores every fetched price in the history store. Only fetches
+// of the default prices key record, so each fetch is sampled once.
+func (s *Service) recordPrices(now time.Time, prices PriceOutput) {
+	if s.History == nil {
+		return
+	}
+
+	for symbol, price := range prices {
+		value, err := json.Marshal(price)
+		if err != nil {
+			s.Echo.Logger.Errorf("recordPrices: %v - %v", ErrMarshalResponse, err)
+			continue
+		}
+		if err := s.History.Record(priceHistorySeries(symbol), now, value); err != nil {
+			s.Echo.Logger.Errorf("recordPrices: unable to record price for %s - %v", symbol, err)
+		}
+	}
+}
+
+func (s *Service) getPriceHistory(ctx echov4.Context, symbol string) error {
+	candles := []PriceCandle{}
+	err := s.rangeHistory(ctx, priceHistorySeries(symbol), func(bucket time.Time, value []byte) error {
+		var price float64
+		if err := json.Unmarshal(value, &price); err != nil {
+			return err
+		}
+
+		// samples arrive oldest first, so the last one in a bucket closes it
+		if n := len(candles); n > 0 && candles[n-1].Time.Equal(bucket) {
+			c := &candles[n-1]
+			c.High = math.Max(c.High, price)
+			c.Low = math.Min(c.Low, price)
+			c.Close = price
+			c.Samples++
+			return nil
+		}
+
+		candles = append(candles, PriceCandle{
+			Time:    bucket,
+			Open:    price,
+			High:    price,
+			Low:     price,
+			Close:   price,
+			Samples: 1,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if ctx.QueryParam("format") == "csv" {
+		rows := make([][]string, 0, len(candles))
+		for _, c := range candles {
+			rows = append(rows, historyCSVRow(c.Time, c.Samples, c.Open, c.High, c.Low, c.Close))
+		}
+		return writeHistoryCSV(ctx, "price-"+symbol+".csv", []string{"time", "open", "high", "low", "close", "samples"}, rows)
+	}
+
+	return ctx.JSON(http.StatusOK, PriceHistoryResponse{Symbol: symbol, Candles: candles})
+}
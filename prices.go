@@ -0,0 +1,684 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	echov4 "github.com/labstack/echo/v4"
+)
+
+// PriceConfig selects the price sources behind /prices and how their quotes are
+// combined. Without sources, prices come from CoinMarketCap using cmc_slugs.
+// Currencies are quoted when no convert parameter is given and default to USD.
+// SupportedCurrencies are the codes convert may request; they default to
+// the major fiat currencies, BTC and ETH.
+//
+// Denoms maps zone base denoms to the symbol their q-asset is priced from;
+// unlisted denoms drop their u or a prefix, so uatom prices qATOM from ATOM.
+type PriceConfig struct {
+	Policy              string              `yaml:"policy" json:"policy"`
+	Sources             []PriceSourceConfig `yaml:"sources" json:"sources"`
+	Currencies          []string            `yaml:"currencies" json:"currencies"`
+	SupportedCurrencies []string            `yaml:"supported_currencies" json:"supported_currencies"`
+	Denoms              map[string]string   `yaml:"denoms" json:"denoms"`
+}
+
+// PriceSourceConfig configures a single price source. Ids maps each symbol to
+// the source's own identifier (a CMC slug or CoinGecko id); Pools maps each
+// symbol to the Osmosis pool its TWAP is read from. Weight is only used by the
+// weighted policy and defaults to 1.
+type PriceSourceConfig struct {
+	Source        string                     `yaml:"source" json:"source"`
+	Endpoint      string                     `yaml:"endpoint" json:"endpoint"`
+	Weight        float64                    `yaml:"weight" json:"weight"`
+	Ids           map[string]string          `yaml:"ids" json:"ids"`
+	Pools         map[string]OsmosisTWAPPool `yaml:"pools" json:"pools"`
+	WindowMinutes int                        `yaml:"window_minutes" json:"window_minutes"`
+}
+
+// OsmosisTWAPPool prices BaseDenom in QuoteDenom, which should be a USD
+// stablecoin. Decimals default to 6.
+type OsmosisTWAPPool struct {
+	PoolID        uint64 `yaml:"pool_id" json:"pool_id"`
+	BaseDenom     string `yaml:"base_denom" json:"base_denom"`
+	QuoteDenom    string `yaml:"quote_denom" json:"quote_denom"`
+	BaseDecimals  int    `yaml:"base_decimals" json:"base_decimals"`
+	QuoteDecimals int    `yaml:"quote_decimals" json:"quote_decimals"`
+}
+
+const (
+	PricePolicyFirstSuccess = "first-success"
+	PricePolicyMedian       = "median"
+	PricePolicyWeighted     = "weighted"
+
+	defaultPriceSource      = "cmc"
+	defaultPriceCurrency    = "USD"
+	maxPriceCurrencies      = 10
+	defaultTWAPWindow       = 30 * time.Minute
+	defaultDenomDecimals    = 6
+	defaultCoinGeckoURL     = "https://api.coingecko.com/api/v3"
+	defaultCoinMarketCapURL = "https://pro-api.coinmarketcap.com"
+)
+
+// defaultSupportedCurrencies are the currencies convert may request when the
+// prices config does not list its own.
+var defaultSupportedCurrencies = []string{"USD", "EUR", "GBP", "JPY", "CHF", "CAD", "AUD", "CNY", "KRW", "BTC", "ETH"}
+
+// PriceOutput maps symbols to prices in a single currency.
+type PriceOutput map[string]float64
+
+// PriceQuotes maps symbols to their price in each quoted currency.
+type PriceQuotes map[string]map[string]float64
+
+// In returns the prices quoted in currency.
+func (q PriceQuotes) In(currency string) PriceOutput {
+	output := PriceOutput{}
+	for symbol, prices := range q {
+		if price, ok := prices[currency]; ok {
+			output[symbol] = price
+		}
+	}
+	return output
+}
+
+// only returns the quotes in currencies, dropping symbols left without any.
+func (q PriceQuotes) only(currencies []string) PriceQuotes {
+	output := PriceQuotes{}
+	for _, currency := range currencies {
+		for symbol, price := range q.In(currency) {
+			output.set(symbol, currency, price)
+		}
+	}
+	return output
+}
+
+func (q PriceQuotes) set(symbol string, currency string, price float64) {
+	if q[symbol] == nil {
+		q[symbol] = map[string]float64{}
+	}
+	q[symbol][currency] = price
+}
+
+// PriceSource returns prices keyed by symbol then currency, for whichever of
+// the requested currencies it supports. Currency codes are upper case.
+type PriceSource interface {
+	Prices(client *http.Client, currencies []string) (PriceQuotes, error)
+}
+
+// PriceSourceFactory builds a PriceSource from its configuration.
+type PriceSourceFactory func(cfg Config, pcfg PriceSourceConfig) PriceSource
+
+var priceSources = map[string]PriceSourceFactory{
+	"cmc": func(cfg Config, pcfg PriceSourceConfig) PriceSource {
+		slugs := cfg.CMCSlugs
+		if len(pcfg.Ids) > 0 {
+			slugs = []string{}
+			for _, slug := range pcfg.Ids {
+				slugs = append(slugs, slug)
+			}
+			sort.Strings(slugs)
+		}
+		return &CMCPrices{endpoint: pcfg.Endpoint, slugs: slugs}
+	},
+	"coingecko": func(_ Config, pcfg PriceSourceConfig) PriceSource {
+		return &CoinGeckoPrices{endpoint: pcfg.Endpoint, ids: pcfg.Ids}
+	},
+	"osmosis": func(_ Config, pcfg PriceSourceConfig) PriceSource {
+		window := time.Duration(pcfg.WindowMinutes) * time.Minute
+		if window <= 0 {
+			window = defaultTWAPWindow
+		}
+		return &OsmosisTWAPPrices{endpoint: pcfg.Endpoint, pools: pcfg.Pools, window: window}
+	},
+}
+
+// RegisterPriceSource makes a price source available to the prices config under name.
+func RegisterPriceSource(name string, factory PriceSourceFactory) {
+	priceSources[name] = factory
+}
+
+// PriceQuery describes a /prices request. Nested responses are keyed by symbol
+// then currency; otherwise prices are flat in the single requested currency.
+// Convert is set only when the request chose its own currencies.
+type PriceQuery struct {
+	Currencies []string
+	Convert    bool
+	Nested     bool
+	Provenance bool
+}
+
+// cacheKey gives each currency combination its own entry. The default
+// currencies keep the plain prices key that the refresher warms.
+func (q PriceQuery) cacheKey() string {
+	key := "prices"
+	if q.Provenance {
+		key += ".provenance"
+	}
+	if q.Convert {
+		key += "." + strings.Join(q.Currencies, ",")
+	}
+	return key
+}
+
+// defaultPriceQuery quotes the configured default currencies, nesting the
+// response only when there is more than one.
+func (s *Service) defaultPriceQuery() PriceQuery {
+	currencies := normalizeCurrencies(s.Config.Prices.Currencies)
+	if len(currencies) == 0 {
+		currencies = []string{defaultPriceCurrency}
+	}
+	return PriceQuery{Currencies: currencies, Nested: len(currencies) > 1}
+}
+
+// parsePriceQuery reads the convert and provenance query parameters. convert
+// is a comma separated list of currency codes and always nests the response.
+func (s *Service) parsePriceQuery(ctx echov4.Context) (PriceQuery, error) {
+	query := s.defaultPriceQuery()
+	query.Provenance = ctx.QueryParam("provenance") == "true"
+
+	convert := ctx.QueryParam("convert")
+	if convert == "" {
+		return query, nil
+	}
+
+	currencies := normalizeCurrencies(strings.Split(convert, ","))
+	if len(currencies) == 0 || len(currencies) > maxPriceCurrencies {
+		return PriceQuery{}, fmt.Errorf("convert must list between 1 and %d currencies", maxPriceCurrencies)
+	}
+	for _, currency := range currencies {
+		if !s.supportsCurrency(currency) {
+			return PriceQuery{}, fmt.Errorf("unsupported currency %q: expected one of %s", currency, strings.Join(s.supportedCurrencies(), ", "))
+		}
+	}
+
+	query.Currencies = currencies
+	query.Convert = true
+	query.Nested = true
+	return query, nil
+}
+
+// normalizeCurrencies upper cases, deduplicates and sorts currency codes.
+func normalizeCurrencies(currencies []string) []string {
+	seen := map[string]struct{}{}
+	normalized := []string{}
+	for _, currency := range currencies {
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		if _, ok := seen[currency]; ok || currency == "" {
+			continue
+		}
+		seen[currency] = struct{}{}
+		normalized = append(normalized, currency)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// supportedCurrencies returns the currency codes convert may request.
+func (s *Service) supportedCurrencies() []string {
+	if currencies := normalizeCurrencies(s.Config.Prices.SupportedCurrencies); len(currencies) > 0 {
+		return currencies
+	}
+	return defaultSupportedCurrencies
+}
+
+func (s *Service) supportsCurrency(currency string) bool {
+	for _, supported := range s.supportedCurrencies() {
+		if currency == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// QAssetPrice records the inputs a q-asset price was derived from.
+type QAssetPrice struct {
+	Price          float64 `json:"price"`
+	BaseSymbol     string  `json:"base_symbol"`
+	BasePrice      float64 `json:"base_price"`
+	RedemptionRate float64 `json:"redemption_rate"`
+	ChainID        string  `json:"chain_id"`
+	Height         int64   `json:"height"`
+}
+
+// QAssetPrices maps q-asset symbols to their derivation in each currency.
+type QAssetPrices map[string]map[string]QAssetPrice
+
+// In returns the derivations in currency.
+func (q QAssetPrices) In(currency string) map[string]QAssetPrice {
+	output := map[string]QAssetPrice{}
+	for symbol, prices := range q {
+		if price, ok := prices[currency]; ok {
+			output[symbol] = price
+		}
+	}
+	return output
+}
+
+// PriceProvenanceResponse is returned by /prices?provenance=true. Prices and
+// Derived follow the same flat or nested shape as the plain response.
+type PriceProvenanceResponse struct {
+	Prices  interface{} `json:"prices"`
+	Derived interface{} `json:"derived"`
+}
+
+// PriceSourceError records why a single price source failed. They are returned
+// as the details of the error when every source fails.
+type PriceSourceError struct {
+	Source string `json:"source"`
+	Error  string `json:"error"`
+}
+
+func (s *Service) priceSourceConfigs() []PriceSourceConfig {
+	if len(s.Config.Prices.Sources) == 0 {
+		return []PriceSourceConfig{{Source: defaultPriceSource}}
+	}
+	return s.Config.Prices.Sources
+}
+
+// validPricePolicy reports whether policy names a price aggregation policy.
+// An empty policy selects first-success.
+func validPricePolicy(policy string) bool {
+	switch policy {
+	case "", PricePolicyFirstSuccess, PricePolicyMedian, PricePolicyWeighted:
+		return true
+	}
+	return false
+}
+
+// queryPrices combines the quotes of the configured sources using the
+// configured policy. Under first-success the sources are queried one after
+// another in config order until one succeeds; otherwise they are queried
+// concurrently. It only fails when no source succeeds.
+func (s *Service) queryPrices(currencies []string) (PriceQuotes, error) {
+	client := &http.Client{Timeout: time.Duration(5) * time.Second}
+	configs := s.priceSourceConfigs()
+
+	quotes := make([]PriceQuotes, len(configs))
+	failures := make([]*PriceSourceError, len(configs))
+
+	policy := s.Config.Prices.Policy
+	if policy == "" || policy == PricePolicyFirstSuccess {
+		for i, pcfg := range configs {
+			quotes[i], failures[i] = s.querySource(client, pcfg, currencies)
+			if failures[i] == nil {
+				break
+			}
+		}
+	} else {
+		var wg sync.WaitGroup
+		wg.Add(len(configs))
+		for i, pcfg := range configs {
+			go func(i int, pcfg PriceSourceConfig) {
+				defer wg.Done()
+				quotes[i], failures[i] = s.querySource(client, pcfg, currencies)
+			}(i, pcfg)
+		}
+		wg.Wait()
+	}
+
+	succeeded := false
+	sourceErrors := []PriceSourceError{}
+	for i, failure := range failures {
+		if failure != nil {
+			s.Echo.Logger.Warnf("getPrices: price source %s failed - %s", failure.Source, failure.Error)
+			sourceErrors = append(sourceErrors, *failure)
+		}
+		succeeded = succeeded || quotes[i] != nil
+	}
+	if !succeeded {
+		apiErr := sentinelError(ErrUnableToGetPrices)
+		apiErr.Upstream = "prices"
+		apiErr.Details = sourceErrors
+		return nil, apiErr
+	}
+
+	weights := make([]float64, len(configs))
+	for i, pcfg := range configs {
+		weights[i] = pcfg.Weight
+		if weights[i] <= 0 {
+			weights[i] = 1
+		}
+	}
+
+	return aggregatePrices(policy, quotes, weights), nil
+}
+
+// querySource queries a single price source for currencies, describing why
+// it failed otherwise.
+func (s *Service) querySource(client *http.Client, pcfg PriceSourceConfig, currencies []string) (PriceQuotes, *PriceSourceError) {
+	factory, ok := priceSources[pcfg.Source]
+	if !ok {
+		return nil, &PriceSourceError{Source: pcfg.Source, Error: ErrUnknownPriceSource.Error()}
+	}
+	prices, err := factory(s.Config, pcfg).Prices(client, currencies)
+	prices = prices.only(currencies)
+	if err == nil && len(prices) == 0 {
+		err = fmt.Errorf("no prices returned")
+	}
+	if err != nil {
+		return nil, &PriceSourceError{Source: pcfg.Source, Error: err.Error()}
+	}
+	return prices, nil
+}
+
+// aggregatePrices combines per-source quotes symbol by symbol and currency by
+// currency. quotes is in source order; a nil entry is a failed or unqueried
+// source. policy must be valid; an empty policy is first-success.
+func aggregatePrices(policy string, quotes []PriceQuotes, weights []float64) PriceQuotes {
+
+	type pair struct{ symbol, currency string }
+	pairs := map[pair]struct{}{}
+	for _, prices := range quotes {
+		for symbol, byCurrency := range prices {
+			for currency := range byCurrency {
+				pairs[pair{symbol, currency}] = struct{}{}
+			}
+		}
+	}
+
+	output := PriceQuotes{}
+	for p := range pairs {
+		values := []float64{}
+		valueWeights := []float64{}
+		for i, prices := range quotes {
+			if price, ok := prices[p.symbol][p.currency]; ok && price > 0 && !math.IsInf(price, 0) {
+				values = append(values, price)
+				valueWeights = append(valueWeights, weights[i])
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		switch policy {
+		case PricePolicyMedian:
+			output.set(p.symbol, p.currency, median(values))
+		case PricePolicyWeighted:
+			var sum, total float64
+			for i, value := range values {
+				sum += value * valueWeights[i]
+				total += valueWeights[i]
+			}
+			output.set(p.symbol, p.currency, sum/total)
+		default:
+			output.set(p.symbol, p.currency, values[0])
+		}
+	}
+
+	return output
+}
+
+// denomSymbol returns the price symbol for a zone base denom.
+func (s *Service) denomSymbol(denom string) string {
+	if symbol, ok := s.Config.Prices.Denoms[denom]; ok {
+		return symbol
+	}
+	if len(denom) > 1 && (denom[0] == 'u' || denom[0] == 'a') {
+		denom = denom[1:]
+	}
+	return strings.ToUpper(denom)
+}
+
+// deriveQAssetPrices prices each zone's q-asset in every quoted currency as its
+// base asset price times the zone redemption rate. Zones whose base asset has
+// no price are skipped, as are all q-assets if the zones cannot be queried.
+func (s *Service) deriveQAssetPrices(prices PriceQuotes) QAssetPrices {
+	derived := QAssetPrices{}
+
+	zones, height, err := s.queryZones(0)
+	if err != nil {
+		s.Echo.Logger.Warnf("getPrices: skipping q-asset prices - %v", err)
+		return derived
+	}
+
+	for _, zone := range zones.Zones {
+		symbol := s.denomSymbol(zone.BaseDenom)
+		basePrices, ok := prices[symbol]
+		if !ok || zone.RedemptionRate.IsNil() {
+			continue
+		}
+		rate, err := zone.RedemptionRate.Float64()
+		if err != nil || rate <= 0 {
+			continue
+		}
+
+		byCurrency := map[string]QAssetPrice{}
+		for currency, basePrice := range basePrices {
+			byCurrency[currency] = QAssetPrice{
+				Price:          basePrice * rate,
+				BaseSymbol:     symbol,
+				BasePrice:      basePrice,
+				RedemptionRate: rate,
+				ChainID:        zone.ChainId,
+				Height:         height,
+			}
+		}
+		derived["q"+symbol] = byCurrency
+	}
+
+	return derived
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// getJSON sends req and decodes the response body into result, treating non-2xx
+// responses as errors.
+func getJSON(client *http.Client, req *http.Request, result interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	return json.Unmarshal(body, result)
+}
+
+// CMCPrices reads quotes from the CoinMarketCap pro API using CMC_KEY.
+type CMCPrices struct {
+	endpoint string
+	slugs    []string
+}
+
+func (p *CMCPrices) Prices(client *http.Client, currencies []string) (PriceQuotes, error) {
+	endpoint := p.endpoint
+	if endpoint == "" {
+		endpoint = defaultCoinMarketCapURL
+	}
+
+	query := url.Values{}
+	query.Set("slug", strings.Join(p.slugs, ","))
+	query.Set("convert", strings.Join(currencies, ","))
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(endpoint, "/")+"/v1/cryptocurrency/quotes/latest?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("X-CMC_PRO_API_KEY", os.Getenv("CMC_KEY"))
+
+	var cmcResponse CMCResponse
+	if err := getJSON(client, req, &cmcResponse); err != nil {
+		return nil, err
+	}
+	if cmcResponse.Status.ErrorCode != 0 {
+		return nil, fmt.Errorf("cmc error %d: %s", cmcResponse.Status.ErrorCode, cmcResponse.Status.ErrorMessage)
+	}
+
+	prices := PriceQuotes{}
+	for _, data := range cmcResponse.Data {
+		for currency, quote := range data.Quote {
+			prices.set(data.Symbol, currency, quote.Price)
+		}
+	}
+	return prices, nil
+}
+
+type CMCStatus struct {
+	ErrorCode    int    `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+	Elapsed      int    `json:"elapsed"`
+	CreditCount  int    `json:"credit_count"`
+}
+
+type CMCResponse struct {
+	Status CMCStatus          `json:"status"`
+	Data   map[string]CMCData `json:"data"`
+}
+
+type CMCData struct {
+	Symbol string              `json:"symbol"`
+	Quote  map[string]CMCQuote `json:"quote"`
+}
+
+type CMCQuote struct {
+	Price float64 `json:"price"`
+}
+
+// CoinGeckoPrices reads quotes from the CoinGecko simple price API. COINGECKO_KEY
+// is sent as a pro key for pro-api endpoints and as a demo key otherwise.
+type CoinGeckoPrices struct {
+	endpoint string
+	ids      map[string]string
+}
+
+func (p *CoinGeckoPrices) Prices(client *http.Client, currencies []string) (PriceQuotes, error) {
+	endpoint := p.endpoint
+	if endpoint == "" {
+		endpoint = defaultCoinGeckoURL
+	}
+
+	symbols := map[string]string{}
+	ids := []string{}
+	for symbol, id := range p.ids {
+		symbols[id] = symbol
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	query := url.Values{}
+	query.Set("ids", strings.Join(ids, ","))
+	query.Set("vs_currencies", strings.ToLower(strings.Join(currencies, ",")))
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(endpoint, "/")+"/simple/price?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if key := os.Getenv("COINGECKO_KEY"); key != "" {
+		if strings.Contains(endpoint, "pro-api") {
+			req.Header.Add("x-cg-pro-api-key", key)
+		} else {
+			req.Header.Add("x-cg-demo-api-key", key)
+		}
+	}
+
+	var result map[string]map[string]float64
+	if err := getJSON(client, req, &result); err != nil {
+		return nil, err
+	}
+
+	prices := PriceQuotes{}
+	for id, quote := range result {
+		for currency, price := range quote {
+			prices.set(symbols[id], strings.ToUpper(currency), price)
+		}
+	}
+	return prices, nil
+}
+
+// OsmosisTWAPPrices reads arithmetic TWAPs from Osmosis pools paired against a
+// USD stablecoin, so it only quotes USD. Pools that fail are skipped; it errors
+// only if all do.
+type OsmosisTWAPPrices struct {
+	endpoint string
+	pools    map[string]OsmosisTWAPPool
+	window   time.Duration
+}
+
+func (p *OsmosisTWAPPrices) Prices(client *http.Client, currencies []string) (PriceQuotes, error) {
+	prices := PriceQuotes{}
+	quotesUSD := false
+	for _, currency := range currencies {
+		quotesUSD = quotesUSD || currency == defaultPriceCurrency
+	}
+	if !quotesUSD {
+		return prices, nil
+	}
+
+	var lastErr error
+	for symbol, pool := range p.pools {
+		price, err := p.twap(client, pool)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", symbol, err)
+			continue
+		}
+		prices.set(symbol, defaultPriceCurrency, price)
+	}
+	if len(prices) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return prices, nil
+}
+
+func (p *OsmosisTWAPPrices) twap(client *http.Client, pool OsmosisTWAPPool) (float64, error) {
+	query := url.Values{}
+	query.Set("pool_id", strconv.FormatUint(pool.PoolID, 10))
+	query.Set("base_asset", pool.BaseDenom)
+	query.Set("quote_asset", pool.QuoteDenom)
+	query.Set("start_time", time.Now().Add(-p.window).UTC().Format(time.RFC3339))
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(p.endpoint, "/")+"/osmosis/twap/v1beta1/ArithmeticTwapToNow?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		ArithmeticTwap sdkmath.LegacyDec `json:"arithmetic_twap"`
+	}
+	if err := getJSON(client, req, &result); err != nil {
+		return 0, err
+	}
+	if result.ArithmeticTwap.IsNil() {
+		return 0, fmt.Errorf("no twap for pool %d", pool.PoolID)
+	}
+
+	twap, err := result.ArithmeticTwap.Float64()
+	if err != nil {
+		return 0, err
+	}
+
+	// the twap is a ratio of base units; scale it to whole tokens
+	baseDecimals, quoteDecimals := pool.BaseDecimals, pool.QuoteDecimals
+	if baseDecimals == 0 {
+		baseDecimals = defaultDenomDecimals
+	}
+	if quoteDecimals == 0 {
+		quoteDecimals = defaultDenomDecimals
+	}
+	return twap * math.Pow10(baseDecimals-quoteDecimals), nil
+}
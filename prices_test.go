@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	echov4 "github.com/labstack/echo/v4"
+)
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"single", []float64{3}, 3},
+		{"odd", []float64{9, 1, 5}, 5},
+		{"even", []float64{4, 1, 3, 2}, 2.5},
+		{"even pair", []float64{10, 20}, 15},
+		{"duplicates", []float64{2, 2, 8}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := append([]float64(nil), tt.values...)
+			if got := median(values); got != tt.want {
+				t.Errorf("median(%v) = %v, want %v", tt.values, got, tt.want)
+			}
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Errorf("median reordered its input to %v", values)
+			}
+		})
+	}
+}
+
+func TestAggregatePrices(t *testing.T) {
+	quote := func(usd float64) PriceQuotes {
+		return PriceQuotes{"ATOM": {"USD": usd}}
+	}
+
+	tests := []struct {
+		name    string
+		policy  string
+		quotes  []PriceQuotes
+		weights []float64
+		want    PriceQuotes
+	}{
+		{
+			name:    "first-success takes the first source in order",
+			policy:  PricePolicyFirstSuccess,
+			quotes:  []PriceQuotes{quote(10), quote(12)},
+			weights: []float64{1, 1},
+			want:    quote(10),
+		},
+		{
+			name:    "empty policy is first-success",
+			policy:  "",
+			quotes:  []PriceQuotes{quote(12), quote(10)},
+			weights: []float64{1, 1},
+			want:    quote(12),
+		},
+		{
+			name:    "first-success skips a failed source",
+			policy:  PricePolicyFirstSuccess,
+			quotes:  []PriceQuotes{nil, quote(11), quote(12)},
+			weights: []float64{1, 1, 1},
+			want:    quote(11),
+		},
+		{
+			name:    "median of an odd count",
+			policy:  PricePolicyMedian,
+			quotes:  []PriceQuotes{quote(30), quote(10), quote(20)},
+			weights: []float64{1, 1, 1},
+			want:    quote(20),
+		},
+		{
+			name:    "median of an even count",
+			policy:  PricePolicyMedian,
+			quotes:  []PriceQuotes{quote(10), quote(40), quote(20), quote(30)},
+			weights: []float64{1, 1, 1, 1},
+			want:    quote(25),
+		},
+		{
+			name:    "median skips a failed source",
+			policy:  PricePolicyMedian,
+			quotes:  []PriceQuotes{quote(10), nil, quote(20)},
+			weights: []float64{1, 1, 1},
+			want:    quote(15),
+		},
+		{
+			name:    "weighted average",
+			policy:  PricePolicyWeighted,
+			quotes:  []PriceQuotes{quote(10), quote(20)},
+			weights: []float64{3, 1},
+			want:    quote(12.5),
+		},
+		{
+			name:    "weighted skips a failed source",
+			policy:  PricePolicyWeighted,
+			quotes:  []PriceQuotes{nil, quote(10), quote(40)},
+			weights: []float64{100, 2, 1},
+			want:    quote(20),
+		},
+		{
+			name:   "sources need not quote the same symbols",
+			policy: PricePolicyMedian,
+			quotes: []PriceQuotes{
+				{"ATOM": {"USD": 10}},
+				{"ATOM": {"USD": 20}, "OSMO": {"USD": 1, "EUR": 0.9}},
+			},
+			weights: []float64{1, 1},
+			want:    PriceQuotes{"ATOM": {"USD": 15}, "OSMO": {"USD": 1, "EUR": 0.9}},
+		},
+		{
+			name:    "non-positive prices are ignored",
+			policy:  PricePolicyMedian,
+			quotes:  []PriceQuotes{quote(0), quote(-1), quote(7)},
+			weights: []float64{1, 1, 1},
+			want:    quote(7),
+		},
+		{
+			name:    "every source failed",
+			policy:  PricePolicyMedian,
+			quotes:  []PriceQuotes{nil, nil},
+			weights: []float64{1, 1},
+			want:    PriceQuotes{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := aggregatePrices(tt.policy, tt.quotes, tt.weights)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("aggregatePrices() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidPricePolicy(t *testing.T) {
+	for policy, want := range map[string]bool{
+		"":                      true,
+		PricePolicyFirstSuccess: true,
+		PricePolicyMedian:       true,
+		PricePolicyWeighted:     true,
+		"mean":                  false,
+		"Median":                false,
+	} {
+		if got := validPricePolicy(policy); got != want {
+			t.Errorf("validPricePolicy(%q) = %v, want %v", policy, got, want)
+		}
+	}
+}
+
+// stubPriceSource returns fixed quotes, or err, and counts its calls.
+type stubPriceSource struct {
+	quotes PriceQuotes
+	err    error
+	calls  *int
+}
+
+func (p stubPriceSource) Prices(_ *http.Client, _ []string) (PriceQuotes, error) {
+	*p.calls++
+	return p.quotes, p.err
+}
+
+func TestQueryPricesFirstSuccessStopsAtFirstSuccess(t *testing.T) {
+	calls := map[string]*int{}
+	for name, source := range map[string]stubPriceSource{
+		"stub-failing": {err: errors.New("unavailable")},
+		"stub-first":   {quotes: PriceQuotes{"ATOM": {"USD": 10}}},
+		"stub-second":  {quotes: PriceQuotes{"ATOM": {"USD": 12}}},
+	} {
+		source := source
+		source.calls = new(int)
+		calls[name] = source.calls
+		RegisterPriceSource(name, func(Config, PriceSourceConfig) PriceSource { return source })
+	}
+
+	e := echov4.New()
+	e.Logger.SetOutput(io.Discard)
+	s := NewCacheService(e, nil, Config{Prices: PriceConfig{
+		Policy:  PricePolicyFirstSuccess,
+		Sources: []PriceSourceConfig{{Source: "stub-failing"}, {Source: "stub-first"}, {Source: "stub-second"}},
+	}})
+
+	got, err := s.queryPrices([]string{"USD"})
+	if err != nil {
+		t.Fatalf("queryPrices: %v", err)
+	}
+	if want := (PriceQuotes{"ATOM": {"USD": 10}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("queryPrices() = %v, want %v", got, want)
+	}
+	for name, want := range map[string]int{"stub-failing": 1, "stub-first": 1, "stub-second": 0} {
+		if *calls[name] != want {
+			t.Errorf("%s queried %d times, want %d", name, *calls[name], want)
+		}
+	}
+}
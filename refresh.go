@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// RefreshConfig schedules background refreshes of cached endpoints. Jobs are
+// keyed by cache key; validatorList applies to every chain in ValidatorChains.
+// Intervals should be shorter than the TTL of the key they refresh; jitter only
+// ever brings a refresh forward.
+type RefreshConfig struct {
+	JitterSeconds   int                         `yaml:"jitter_seconds" json:"jitter_seconds"`
+	Jobs            map[string]RefreshJobConfig `yaml:"jobs" json:"jobs"`
+	ValidatorChains []string                    `yaml:"validator_chains" json:"validator_chains"`
+}
+
+type RefreshJobConfig struct {
+	IntervalSeconds int `yaml:"interval_seconds" json:"interval_seconds"`
+	// JitterSeconds overrides the global jitter for this job.
+	JitterSeconds *int `yaml:"jitter_seconds" json:"jitter_seconds,omitempty"`
+}
+
+// RefreshJob re-fetches a single cache key on a fixed interval.
+type RefreshJob struct {
+	Key      string
+	Interval time.Duration
+	Jitter   time.Duration
+	Run      func() ([]byte, error)
+}
+
+// refreshFuncs returns the fetch function for every cache key the refresher
+// knows how to rebuild.
+func (s *Service) refreshFuncs() map[string]func() ([]byte, error) {
+	funcs := map[string]func() ([]byte, error){
+		"zones":              func() ([]byte, error) { return s.getZones("zones", 0) },
+		"apr":                func() ([]byte, error) { return s.getAPR("apr") },
+		"total_supply":       func() ([]byte, error) { return s.getSupply("total_supply") },
+		"circulating_supply": func() ([]byte, error) { return s.getCirculatingSupply("circulating_supply") },
+		"prices":             func() ([]byte, error) { return s.getPrices("prices", s.defaultPriceQuery()) },
+		"defi":               func() ([]byte, error) { return s.refreshDefi("defi") },
+	}
+
+	for _, chainId := range s.Config.Refresh.ValidatorChains {
+		chain, err := s.Registry.Lookup(chainId)
+		if err != nil {
+			s.Echo.Logger.Warnf("refresher: skipping validatorList for %s - %v", chainId, err)
+			continue
+		}
+		key := validatorListKey(chain.ChainID)
+		funcs[key] = func() ([]byte, error) { return s.getValidatorList(key, chain, 0) }
+	}
+
+	return funcs
+}
+
+// refreshJobs builds the configured jobs. Keys without a positive interval are
+// left read-through.
+func (s *Service) refreshJobs() []RefreshJob {
+	cfg := s.Config.Refresh
+	jobs := []RefreshJob{}
+
+	for key, run := range s.refreshFuncs() {
+		jobName := key
+		if _, ok := cfg.Jobs[jobName]; !ok {
+			// per-chain jobs share the validatorList schedule
+			if strings.HasPrefix(key, "validatorList.") {
+				jobName = "validatorList"
+			}
+		}
+
+		jobCfg, ok := cfg.Jobs[jobName]
+		if !ok || jobCfg.IntervalSeconds <= 0 {
+			continue
+		}
+
+		jitter := cfg.JitterSeconds
+		if jobCfg.JitterSeconds != nil {
+			jitter = *jobCfg.JitterSeconds
+		}
+
+		jobs = append(jobs, RefreshJob{
+			Key:      key,
+			Interval: time.Duration(jobCfg.IntervalSeconds) * time.Second,
+			Jitter:   time.Duration(jitter) * time.Second,
+			Run:      run,
+		})
+	}
+
+	return jobs
+}
+
+// startRefresher runs every configured refresh job in its own goroutine until ctx is cancelled.
+func (s *Service) startRefresher(ctx context.Context) {
+	for _, job := range s.refreshJobs() {
+		s.Echo.Logger.Infof("refresher: scheduling %s every %s", job.Key, job.Interval)
+		go s.runRefreshJob(ctx, job)
+	}
+}
+
+func (s *Service) runRefreshJob(ctx context.Context, job RefreshJob) {
+	// the first run is spread over the jitter window so jobs do not all hit
+	// upstreams at startup; later runs come up to one jitter window early
+	delay := jitterDuration(job.Jitter)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		start := time.Now()
+		if _, _, err := s.fetchOnce(job.Key, job.Run); err != nil {
+			s.Echo.Logger.Errorf("refresher: %s failed after %s - %v", job.Key, time.Since(start), err)
+		}
+
+		delay = job.Interval - jitterDuration(job.Jitter)
+		if delay < 0 {
+			delay = 0
+		}
+	}
+}
+
+func jitterDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
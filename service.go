@@ -1,12 +1,13 @@
 package main
 
 import (
+	"sync"
 	"time"
 
-	"github.com/dgraph-io/ristretto"
 	echov4 "github.com/labstack/echo/v4"
 	tmhttp "github.com/tendermint/tendermint/rpc/client/http"
 	libclient "github.com/tendermint/tendermint/rpc/jsonrpc/client"
+	"golang.org/x/sync/singleflight"
 )
 
 const LogoStr = `
@@ -44,24 +45,64 @@ const LogoStr = `
 `
 
 type Service struct {
-	Config Config
+	Config   Config
+	History  *HistoryStore
+	Registry *ChainRegistry
+
+	inflight     singleflight.Group
+	revalidating sync.Map
 
 	*echov4.Echo
-	*ristretto.Cache
+	Cache Cache
 }
 
 type Config struct {
-	CMCSlugs          []string   `yaml:"cmc_slugs" json:"cmc_slugs"`
-	RpcEndpoint       string     `yaml:"rpc_endpoint" json:"rpc_endpoint"`
-	LcdEndpoint       string     `yaml:"lcd_endpoint" json:"lcd_endpoint"`
-	SupplyLcdEndpoint string     `yaml:"supply_lcd_endpoint" json:"supply_lcd_endpoint"`
-	ChainHost         string     `yaml:"chain_rpc_endpoint" json:"chain_rpc_endpoint"`
-	Chains            []string   `yaml:"chains" json:"chains"`
-	APRURL            string     `yaml:"apr_url" json:"apr_url"`
-	APRCacheTime      int        `yaml:"apr_cache_minutes" json:"apr_cache_minutes"`
-	SupplyCacheTime   int        `yaml:"supply_cache_minutes" json:"supply_cache_minutes"`
-	DefiInfo          []DefiInfo `yaml:"defi" json:"defi"`
-	DefiApis          DefiApis   `yaml:"defi_apis" json:"defi_apis"`
+	CMCSlugs          []string                     `yaml:"cmc_slugs" json:"cmc_slugs"`
+	Prices            PriceConfig                  `yaml:"prices" json:"prices"`
+	RpcEndpoint       string                       `yaml:"rpc_endpoint" json:"rpc_endpoint"`
+	LcdEndpoint       string                       `yaml:"lcd_endpoint" json:"lcd_endpoint"`
+	SupplyLcdEndpoint string                       `yaml:"supply_lcd_endpoint" json:"supply_lcd_endpoint"`
+	ChainHost         string                       `yaml:"chain_rpc_endpoint" json:"chain_rpc_endpoint"`
+	ChainLcdHost      string                       `yaml:"chain_lcd_endpoint" json:"chain_lcd_endpoint"`
+	ChainRegistry     []ChainConfig                `yaml:"chain_registry" json:"chain_registry"`
+	Chains            []string                     `yaml:"chains" json:"chains"`
+	APRURL            string                       `yaml:"apr_url" json:"apr_url"`
+	APRCacheTime      int                          `yaml:"apr_cache_minutes" json:"apr_cache_minutes"`
+	APRProviders      map[string]APRProviderConfig `yaml:"apr_providers" json:"apr_providers"`
+	APRFeeRate        *float64                     `yaml:"apr_fee_rate" json:"apr_fee_rate"`
+	APRCompounding    *float64                     `yaml:"apr_compounding_periods" json:"apr_compounding_periods"`
+	SupplyCacheTime   int                          `yaml:"supply_cache_minutes" json:"supply_cache_minutes"`
+	HistoryPath       string                       `yaml:"history_path" json:"history_path"`
+	APRSampleTime     int                          `yaml:"apr_sample_minutes" json:"apr_sample_minutes"`
+	Refresh           RefreshConfig                `yaml:"refresh" json:"refresh"`
+	Stale             StaleConfig                  `yaml:"stale" json:"stale"`
+	Cache             CacheConfig                  `yaml:"cache" json:"cache"`
+	AdminToken        string                       `yaml:"admin_token" json:"-"`
+	Snapshot          SnapshotConfig               `yaml:"snapshot" json:"snapshot"`
+	Portfolio         PortfolioConfig              `yaml:"portfolio" json:"portfolio"`
+	DefiInfo          []DefiInfo                   `yaml:"defi" json:"defi"`
+	DefiApis          DefiApis                     `yaml:"defi_apis" json:"defi_apis"`
+}
+
+// APRProviderConfig selects and parameterises the APR provider and fee model for a
+// single chain. Chains without an entry use the cosmos.directory provider and the
+// default fee model. Endpoint is the provider's own base URL; cosmos.directory
+// falls back to apr_url without one.
+type APRProviderConfig struct {
+	Provider       string  `yaml:"provider" json:"provider"`
+	ChainID        string  `yaml:"chain_id" json:"chain_id"`
+	Endpoint       string  `yaml:"endpoint" json:"endpoint"`
+	ProvisionsPath string  `yaml:"provisions_path" json:"provisions_path"`
+	PoolPath       string  `yaml:"pool_path" json:"pool_path"`
+	Field          string  `yaml:"field" json:"field"`
+	Value          float64 `yaml:"value" json:"value"`
+
+	// FeeRate overrides the on-chain commission rate; CompoundingPeriods overrides
+	// apr_compounding_periods. IsAPY marks provider values that are already
+	// compounded, so only the fee is applied.
+	FeeRate            *float64 `yaml:"fee_rate" json:"fee_rate,omitempty"`
+	CompoundingPeriods *float64 `yaml:"compounding_periods" json:"compounding_periods,omitempty"`
+	IsAPY              bool     `yaml:"is_apy" json:"is_apy"`
 }
 
 type DefiInfo struct {
@@ -81,7 +122,7 @@ type DefiApis struct {
 	Shade      string `yaml:"shade" json:"shade"`
 }
 
-func NewCacheService(e *echov4.Echo, cache *ristretto.Cache, cfg Config) *Service {
+func NewCacheService(e *echov4.Echo, cache Cache, cfg Config) *Service {
 	return &Service{
 		Config: cfg,
 		Echo:   e,
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SnapshotConfig enables persisting the cache to Path on shutdown and every
+// IntervalMinutes, and reloading it at startup. It only applies to backends
+// that implement Snapshotter.
+type SnapshotConfig struct {
+	Path            string `yaml:"path" json:"path"`
+	IntervalMinutes int    `yaml:"interval_minutes" json:"interval_minutes"`
+}
+
+func init() {
+	// every concrete type stored in the cache must be registered so it can be
+	// snapshotted or stored in redis
+	gob.Register([]byte{})
+	gob.Register(float64(0))
+	gob.Register(cachedResponse{})
+	gob.Register(map[string]json.RawMessage{})
+	gob.Register(DefiInfo{})
+	gob.Register([]UxResult{})
+	gob.Register([]ShadeResult{})
+	gob.Register(OsmosisPoolCacheResult{})
+}
+
+// Snapshotter is implemented by cache backends that lose their contents on restart.
+type Snapshotter interface {
+	SaveSnapshot(path string) (int, error)
+	LoadSnapshot(path string) (int, error)
+}
+
+var _ Snapshotter = (*RistrettoCache)(nil)
+
+type snapshotEntry struct {
+	Key       string
+	Value     []byte
+	ExpiresAt time.Time
+}
+
+// cacheValue wraps a cached value so gob records its concrete type.
+type cacheValue struct {
+	V interface{}
+}
+
+func encodeCacheValue(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cacheValue{V: value}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeCacheValue(data []byte) (interface{}, error) {
+	var value cacheValue
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return value.V, nil
+}
+
+// SaveSnapshot writes every live cache entry to path, replacing the previous
+// snapshot atomically. Entries whose type cannot be encoded are skipped.
+func (c *RistrettoCache) SaveSnapshot(path string) (int, error) {
+	// apply pending sets so the snapshot includes them
+	c.Wait()
+
+	entries := []snapshotEntry{}
+	for key, expiresAt := range c.Keys() {
+		value, found := c.Get(key)
+		if !found {
+			continue
+		}
+		data, err := encodeCacheValue(value)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, snapshotEntry{Key: key, Value: data, ExpiresAt: expiresAt})
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(entries); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	return len(entries), os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot restores the entries in path that have not yet expired, keeping
+// their remaining TTL. A missing snapshot is not an error.
+func (c *RistrettoCache) LoadSnapshot(path string) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var entries []snapshotEntry
+	if err := gob.NewDecoder(f).Decode(&entries); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	restored := 0
+	for _, entry := range entries {
+		var ttl time.Duration
+		if !entry.ExpiresAt.IsZero() {
+			ttl = entry.ExpiresAt.Sub(now)
+			if ttl <= 0 {
+				continue
+			}
+		}
+
+		value, err := decodeCacheValue(entry.Value)
+		if err != nil {
+			continue
+		}
+		if c.SetWithTTL(entry.Key, value, ttl) {
+			restored++
+		}
+	}
+	c.Wait()
+
+	return restored, nil
+}
+
+// snapshotCache saves the cache snapshot every interval until ctx is cancelled.
+func (s *Service) snapshotCache(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.saveSnapshot()
+		}
+	}
+}
+
+func (s *Service) saveSnapshot() {
+	snapshotter, ok := s.Cache.(Snapshotter)
+	if !ok {
+		return
+	}
+
+	saved, err := snapshotter.SaveSnapshot(s.Config.Snapshot.Path)
+	if err != nil {
+		s.Echo.Logger.Errorf("saveSnapshot: %v - %v", ErrSaveSnapshot, err)
+		return
+	}
+	s.Echo.Logger.Infof("saveSnapshot: saved %d cache entries", saved)
+}
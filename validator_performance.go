@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	cdctypes "github.com/cosmos/cosmos-sdk/codec/types"
+	cryptocodec "github.com/cosmos/cosmos-sdk/crypto/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	"github.com/cosmos/cosmos-sdk/types/query"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	tmhttp "github.com/tendermint/tendermint/rpc/client/http"
+)
+
+// ValidatorPerformance is the uptime of a validator over the chain's signed
+// blocks window, taken from its slashing signing info.
+type ValidatorPerformance struct {
+	OperatorAddress    string     `json:"operator_address"`
+	Moniker            string     `json:"moniker"`
+	ConsensusAddress   string     `json:"consensus_address"`
+	Status             string     `json:"status"`
+	Jailed             bool       `json:"jailed"`
+	JailedUntil        *time.Time `json:"jailed_until,omitempty"`
+	Tombstoned         bool       `json:"tombstoned"`
+	MissedBlocks       int64      `json:"missed_blocks"`
+	MissedBlocksRatio  sdk.Dec    `json:"missed_blocks_ratio"`
+	Uptime             sdk.Dec    `json:"uptime"`
+	SigningInfoMissing bool       `json:"signing_info_missing,omitempty"`
+}
+
+type ValidatorPerformanceResponse struct {
+	ChainID            string                 `json:"chain_id"`
+	SignedBlocksWindow int64                  `json:"signed_blocks_window"`
+	Validators         []ValidatorPerformance `json:"validators"`
+}
+
+func validatorListKey(chainId string) string {
+	return fmt.Sprintf("validatorList.%s", chainId)
+}
+
+func validatorPerformanceKey(chainId string) string {
+	return fmt.Sprintf("validatorPerformance.%s", chainId)
+}
+
+// getValidatorPerformance computes the uptime of the validators in the cached
+// validator list of chain from their signing infos, read from the same state
+// as the list.
+func (s *Service) getValidatorPerformance(key string, chain ChainConfig) ([]byte, error) {
+	s.Echo.Logger.Infof("getValidatorPerformance")
+
+	listKey := validatorListKey(chain.ChainID)
+	list, err := s.cachedData(listKey, func() ([]byte, error) {
+		return s.getValidatorList(listKey, chain, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	meta := s.cachedChainState(listKey).ChainMeta
+
+	// establish client connection
+	client, err := NewRPCClient(chain.RPC, 30*time.Second)
+	if err != nil {
+		s.Echo.Logger.Errorf("getValidatorPerformance: %v - %v", ErrRPCClientConnection, err)
+		return nil, upstreamError(ErrRPCClientConnection, chain.ChainID+"-rpc", err)
+	}
+
+	// prepare codecs; consensus keys are needed to match signing infos
+	interfaceRegistry := cdctypes.NewInterfaceRegistry()
+	stakingtypes.RegisterInterfaces(interfaceRegistry)
+	cryptocodec.RegisterInterfaces(interfaceRegistry)
+	marshaler := codec.NewProtoCodec(interfaceRegistry)
+
+	listResponse := stakingtypes.QueryValidatorsResponse{}
+	if err := marshaler.UnmarshalJSON(list, &listResponse); err == nil {
+		err = stakingtypes.Validators(listResponse.Validators).UnpackInterfaces(interfaceRegistry)
+	}
+	if err != nil {
+		s.Echo.Logger.Errorf("getValidatorPerformance: %v - %v", ErrUnmarshalResponse, err)
+		return nil, ErrUnmarshalResponse
+	}
+
+	respdata, err := s.queryValidatorPerformance(client, marshaler, chain, listResponse.Validators, meta.Height)
+	if err != nil {
+		s.Echo.Logger.Errorf("getValidatorPerformance: %v - %v", ErrUnableToGetSigningInfos, err)
+		return nil, upstreamError(ErrUnableToGetSigningInfos, chain.ChainID+"-rpc", err)
+	}
+
+	s.setCachedAt(key, respdata, 1*time.Hour, meta)
+
+	return respdata, nil
+}
+
+// queryValidatorPerformance queries the signing info of validators at height
+// over client and returns their performance.
+func (s *Service) queryValidatorPerformance(client *tmhttp.HTTP, marshaler *codec.ProtoCodec, chain ChainConfig, validators stakingtypes.Validators, height int64) ([]byte, error) {
+	abciquery, err := client.ABCIQueryWithOptions(
+		context.Background(),
+		"/cosmos.slashing.v1beta1.Query/Params",
+		marshaler.MustMarshal(&slashingtypes.QueryParamsRequest{}),
+		rpcclient.ABCIQueryOptions{Height: height},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !abciquery.Response.IsOK() {
+		return nil, fmt.Errorf("slashing params failed with code %d: %s", abciquery.Response.Code, abciquery.Response.Log)
+	}
+	params := slashingtypes.QueryParamsResponse{}
+	if err := marshaler.Unmarshal(abciquery.Response.Value, &params); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnmarshalResponse, err)
+	}
+	window := params.Params.SignedBlocksWindow
+
+	// signing infos are keyed by consensus address bytes, whatever the prefix
+	infos := map[string]slashingtypes.ValidatorSigningInfo{}
+	_, err = queryAllPages(client, "/cosmos.slashing.v1beta1.Query/SigningInfos", height,
+		func(page *query.PageRequest) []byte {
+			return marshaler.MustMarshal(&slashingtypes.QuerySigningInfosRequest{Pagination: page})
+		},
+		func(value []byte) (*query.PageResponse, error) {
+			pageResponse := slashingtypes.QuerySigningInfosResponse{}
+			if err := marshaler.Unmarshal(value, &pageResponse); err != nil {
+				return nil, err
+			}
+			for _, info := range pageResponse.Info {
+				_, consAddr, err := bech32.DecodeAndConvert(info.Address)
+				if err != nil {
+					return nil, err
+				}
+				infos[string(consAddr)] = info
+			}
+			return pageResponse.Pagination, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := ValidatorPerformanceResponse{
+		ChainID:            chain.ChainID,
+		SignedBlocksWindow: window,
+		Validators:         []ValidatorPerformance{},
+	}
+	for _, validator := range validators {
+		performance := ValidatorPerformance{
+			OperatorAddress:   validator.OperatorAddress,
+			Moniker:           validator.Description.Moniker,
+			Status:            strings.ToLower(strings.TrimPrefix(validator.Status.String(), "BOND_STATUS_")),
+			Jailed:            validator.Jailed,
+			MissedBlocksRatio: sdk.ZeroDec(),
+			Uptime:            sdk.OneDec(),
+		}
+
+		consAddr, err := validator.GetConsAddr()
+		if err != nil {
+			s.Echo.Logger.Warnf("getValidatorPerformance: no consensus address for %s - %v", validator.OperatorAddress, err)
+			performance.SigningInfoMissing = true
+			resp.Validators = append(resp.Validators, performance)
+			continue
+		}
+		performance.ConsensusAddress, _ = bech32.ConvertAndEncode(chain.Bech32Prefix+"valcons", consAddr)
+
+		info, ok := infos[string(consAddr)]
+		if !ok {
+			performance.SigningInfoMissing = true
+			resp.Validators = append(resp.Validators, performance)
+			continue
+		}
+		performance.Tombstoned = info.Tombstoned
+		performance.MissedBlocks = info.MissedBlocksCounter
+		if info.JailedUntil.After(time.Unix(0, 0)) {
+			jailedUntil := info.JailedUntil
+			performance.JailedUntil = &jailedUntil
+		}
+		if window > 0 {
+			performance.MissedBlocksRatio = sdk.NewDec(info.MissedBlocksCounter).QuoInt64(window)
+			performance.Uptime = sdk.OneDec().Sub(performance.MissedBlocksRatio)
+		}
+		resp.Validators = append(resp.Validators, performance)
+	}
+
+	respdata, err := json.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMarshalResponse, err)
+	}
+	return respdata, nil
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	sdkmath "cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	echov4 "github.com/labstack/echo/v4"
+)
+
+const (
+	ValidatorSortTokens     = "tokens"
+	ValidatorSortCommission = "commission"
+	ValidatorSortMoniker    = "moniker"
+
+	ValidatorViewFull    = "full"
+	ValidatorViewCompact = "compact"
+)
+
+// validatorStatuses maps the status names accepted by /validatorList to bond statuses.
+var validatorStatuses = map[string]stakingtypes.BondStatus{
+	"bonded":    stakingtypes.Bonded,
+	"unbonding": stakingtypes.Unbonding,
+	"unbonded":  stakingtypes.Unbonded,
+}
+
+// ValidatorQuery filters, sorts and projects the cached validator set of a
+// chain. The zero value selects the full set in the order the chain returned it.
+type ValidatorQuery struct {
+	Statuses      map[stakingtypes.BondStatus]bool
+	Jailed        *bool
+	MinCommission *sdk.Dec
+	MaxCommission *sdk.Dec
+	Sort          string
+	Descending    bool
+	View          string
+}
+
+// parseValidatorQuery reads the status, jailed, min_commission, max_commission,
+// sort, order and view parameters of /validatorList.
+func parseValidatorQuery(ctx echov4.Context) (ValidatorQuery, error) {
+	query := ValidatorQuery{View: ValidatorViewFull}
+
+	if raw := ctx.QueryParam("status"); raw != "" {
+		query.Statuses = map[stakingtypes.BondStatus]bool{}
+		for _, name := range strings.Split(raw, ",") {
+			status, ok := validatorStatuses[strings.ToLower(strings.TrimSpace(name))]
+			if !ok {
+				return ValidatorQuery{}, fmt.Errorf("invalid status %q: expected bonded, unbonding or unbonded", name)
+			}
+			query.Statuses[status] = true
+		}
+	}
+
+	if raw := ctx.QueryParam("jailed"); raw != "" {
+		jailed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return ValidatorQuery{}, fmt.Errorf("invalid jailed %q: expected true or false", raw)
+		}
+		query.Jailed = &jailed
+	}
+
+	for param, bound := range map[string]**sdk.Dec{"min_commission": &query.MinCommission, "max_commission": &query.MaxCommission} {
+		raw := ctx.QueryParam(param)
+		if raw == "" {
+			continue
+		}
+		rate, err := sdk.NewDecFromStr(raw)
+		if err != nil || rate.IsNegative() || rate.GT(sdk.OneDec()) {
+			return ValidatorQuery{}, fmt.Errorf("invalid %s %q: expected a rate between 0 and 1", param, raw)
+		}
+		*bound = &rate
+	}
+	if query.MinCommission != nil && query.MaxCommission != nil && query.MinCommission.GT(*query.MaxCommission) {
+		return ValidatorQuery{}, fmt.Errorf("min_commission must not exceed max_commission")
+	}
+
+	switch query.Sort = ctx.QueryParam("sort"); query.Sort {
+	case "":
+	case ValidatorSortTokens:
+		// largest validators first unless asked otherwise
+		query.Descending = true
+	case ValidatorSortCommission, ValidatorSortMoniker:
+	default:
+		return ValidatorQuery{}, fmt.Errorf("invalid sort %q: expected %s, %s or %s", query.Sort, ValidatorSortTokens, ValidatorSortCommission, ValidatorSortMoniker)
+	}
+
+	switch order := ctx.QueryParam("order"); order {
+	case "":
+	case "asc", "desc":
+		if query.Sort == "" {
+			return ValidatorQuery{}, fmt.Errorf("order requires sort")
+		}
+		query.Descending = order == "desc"
+	default:
+		return ValidatorQuery{}, fmt.Errorf("invalid order %q: expected asc or desc", order)
+	}
+
+	switch query.View = ctx.QueryParam("view"); query.View {
+	case "":
+		query.View = ValidatorViewFull
+	case ValidatorViewFull, ValidatorViewCompact:
+	default:
+		return ValidatorQuery{}, fmt.Errorf("invalid view %q: expected %s or %s", query.View, ValidatorViewFull, ValidatorViewCompact)
+	}
+
+	return query, nil
+}
+
+// IsZero reports whether query would return the cached set unchanged.
+func (q ValidatorQuery) IsZero() bool {
+	return q.Statuses == nil && q.Jailed == nil && q.MinCommission == nil && q.MaxCommission == nil &&
+		q.Sort == "" && q.View == ValidatorViewFull
+}
+
+// validatorEntry is a validator of the cached validator list: its original
+// proto JSON alongside the fields used to filter and sort it.
+type validatorEntry struct {
+	Raw             json.RawMessage
+	OperatorAddress string
+	Moniker         string
+	Status          stakingtypes.BondStatus
+	Jailed          bool
+	Tokens          sdkmath.Int
+	Commission      sdk.Dec
+}
+
+// CompactValidator is the projection of a validator returned by view=compact.
+type CompactValidator struct {
+	OperatorAddress string      `json:"operator_address"`
+	Moniker         string      `json:"moniker"`
+	Status          string      `json:"status"`
+	Jailed          bool        `json:"jailed"`
+	Tokens          sdkmath.Int `json:"tokens"`
+	Commission      sdk.Dec     `json:"commission"`
+}
+
+// decodeValidatorList decodes the proto JSON cached by getValidatorList.
+func decodeValidatorList(data []byte) ([]validatorEntry, error) {
+	var list struct {
+		Validators []json.RawMessage `json:"validators"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	entries := make([]validatorEntry, 0, len(list.Validators))
+	for _, raw := range list.Validators {
+		var validator struct {
+			OperatorAddress string `json:"operator_address"`
+			Jailed          bool   `json:"jailed"`
+			Status          string `json:"status"`
+			Tokens          string `json:"tokens"`
+			Description     struct {
+				Moniker string `json:"moniker"`
+			} `json:"description"`
+			Commission struct {
+				CommissionRates struct {
+					Rate string `json:"rate"`
+				} `json:"commission_rates"`
+			} `json:"commission"`
+		}
+		if err := json.Unmarshal(raw, &validator); err != nil {
+			return nil, err
+		}
+
+		tokens, ok := sdkmath.NewIntFromString(validator.Tokens)
+		if !ok {
+			return nil, fmt.Errorf("validator %s has invalid tokens %q", validator.OperatorAddress, validator.Tokens)
+		}
+		commission, err := sdk.NewDecFromStr(validator.Commission.CommissionRates.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("validator %s has invalid commission - %v", validator.OperatorAddress, err)
+		}
+
+		entries = append(entries, validatorEntry{
+			Raw:             raw,
+			OperatorAddress: validator.OperatorAddress,
+			Moniker:         validator.Description.Moniker,
+			Status:          stakingtypes.BondStatus(stakingtypes.BondStatus_value[validator.Status]),
+			Jailed:          validator.Jailed,
+			Tokens:          tokens,
+			Commission:      commission,
+		})
+	}
+	return entries, nil
+}
+
+// Apply filters and sorts validators. Validators that compare equal keep their
+// original order.
+func (q ValidatorQuery) Apply(validators []validatorEntry) []validatorEntry {
+	selected := []validatorEntry{}
+	for _, validator := range validators {
+		if q.Statuses != nil && !q.Statuses[validator.Status] {
+			continue
+		}
+		if q.Jailed != nil && validator.Jailed != *q.Jailed {
+			continue
+		}
+		if q.MinCommission != nil && validator.Commission.LT(*q.MinCommission) {
+			continue
+		}
+		if q.MaxCommission != nil && validator.Commission.GT(*q.MaxCommission) {
+			continue
+		}
+		selected = append(selected, validator)
+	}
+
+	var less func(a, b validatorEntry) bool
+	switch q.Sort {
+	case ValidatorSortTokens:
+		less = func(a, b validatorEntry) bool { return a.Tokens.LT(b.Tokens) }
+	case ValidatorSortCommission:
+		less = func(a, b validatorEntry) bool { return a.Commission.LT(b.Commission) }
+	case ValidatorSortMoniker:
+		less = func(a, b validatorEntry) bool { return strings.ToLower(a.Moniker) < strings.ToLower(b.Moniker) }
+	}
+	if less != nil {
+		sort.SliceStable(selected, func(i, j int) bool {
+			if q.Descending {
+				return less(selected[j], selected[i])
+			}
+			return less(selected[i], selected[j])
+		})
+	}
+
+	return selected
+}
+
+// serveValidatorList responds with the validators of the cached list under key
+// selected by query, along with the chain state the list was read from. The
+// cached list itself is always the full set.
+func (s *Service) serveValidatorList(ctx echov4.Context, key string, query ValidatorQuery, fetch func() ([]byte, error)) error {
+	envelope, err := parseEnvelope(ctx)
+	if err != nil {
+		return invalidParameter(err)
+	}
+
+	data, err := s.cached(ctx, key, fetch)
+	if err != nil {
+		return err
+	}
+	if query.IsZero() {
+		return s.writeChainResponse(ctx, key, data, envelope)
+	}
+
+	validators, err := decodeValidatorList(data)
+	if err != nil {
+		s.Echo.Logger.Errorf("serveValidatorList: %v - %v", ErrUnmarshalResponse, err)
+		return ErrUnmarshalResponse
+	}
+	validators = query.Apply(validators)
+
+	pagination := map[string]interface{}{"next_key": nil, "total": strconv.Itoa(len(validators))}
+
+	var resp map[string]interface{}
+	if query.View == ValidatorViewCompact {
+		compact := make([]CompactValidator, 0, len(validators))
+		for _, validator := range validators {
+			compact = append(compact, CompactValidator{
+				OperatorAddress: validator.OperatorAddress,
+				Moniker:         validator.Moniker,
+				Status:          strings.ToLower(strings.TrimPrefix(validator.Status.String(), "BOND_STATUS_")),
+				Jailed:          validator.Jailed,
+				Tokens:          validator.Tokens,
+				Commission:      validator.Commission,
+			})
+		}
+		resp = map[string]interface{}{"validators": compact, "pagination": pagination}
+	} else {
+		raw := make([]json.RawMessage, 0, len(validators))
+		for _, validator := range validators {
+			raw = append(raw, validator.Raw)
+		}
+		resp = map[string]interface{}{"validators": raw, "pagination": pagination}
+	}
+
+	respdata, err := json.Marshal(resp)
+	if err != nil {
+		s.Echo.Logger.Errorf("serveValidatorList: %v - %v", ErrMarshalResponse, err)
+		return ErrMarshalResponse
+	}
+	return s.writeChainResponse(ctx, key, respdata, envelope)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	sdkmath "cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/dgraph-io/ristretto"
+	echov4 "github.com/labstack/echo/v4"
+)
+
+func newQueryContext(query string) echov4.Context {
+	req := httptest.NewRequest("GET", "/validatorList/cosmoshub-4?"+query, nil)
+	return echov4.New().NewContext(req, httptest.NewRecorder())
+}
+
+func TestParseValidatorQuery(t *testing.T) {
+	dec := func(s string) *sdk.Dec {
+		d := sdk.MustNewDecFromStr(s)
+		return &d
+	}
+	jailed := true
+
+	tests := []struct {
+		query   string
+		want    ValidatorQuery
+		wantErr bool
+	}{
+		{query: "", want: ValidatorQuery{View: ValidatorViewFull}},
+		{
+			query: "status=bonded,Unbonding",
+			want: ValidatorQuery{
+				Statuses: map[stakingtypes.BondStatus]bool{stakingtypes.Bonded: true, stakingtypes.Unbonding: true},
+				View:     ValidatorViewFull,
+			},
+		},
+		{query: "status=active", wantErr: true},
+		{query: "jailed=true", want: ValidatorQuery{Jailed: &jailed, View: ValidatorViewFull}},
+		{query: "jailed=maybe", wantErr: true},
+		{
+			query: "min_commission=0.05&max_commission=0.1",
+			want:  ValidatorQuery{MinCommission: dec("0.05"), MaxCommission: dec("0.1"), View: ValidatorViewFull},
+		},
+		{query: "min_commission=0.1&max_commission=0.1", want: ValidatorQuery{MinCommission: dec("0.1"), MaxCommission: dec("0.1"), View: ValidatorViewFull}},
+		{query: "min_commission=0.2&max_commission=0.1", wantErr: true},
+		{query: "min_commission=-0.1", wantErr: true},
+		{query: "max_commission=1.5", wantErr: true},
+		{query: "max_commission=abc", wantErr: true},
+		{query: "sort=tokens", want: ValidatorQuery{Sort: ValidatorSortTokens, Descending: true, View: ValidatorViewFull}},
+		{query: "sort=tokens&order=asc", want: ValidatorQuery{Sort: ValidatorSortTokens, View: ValidatorViewFull}},
+		{query: "sort=commission", want: ValidatorQuery{Sort: ValidatorSortCommission, View: ValidatorViewFull}},
+		{query: "sort=moniker&order=desc", want: ValidatorQuery{Sort: ValidatorSortMoniker, Descending: true, View: ValidatorViewFull}},
+		{query: "sort=uptime", wantErr: true},
+		{query: "order=desc", wantErr: true},
+		{query: "sort=tokens&order=down", wantErr: true},
+		{query: "view=compact", want: ValidatorQuery{View: ValidatorViewCompact}},
+		{query: "view=summary", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := parseValidatorQuery(newQueryContext(tt.query))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseValidatorQuery(%q) = %+v, want an error", tt.query, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseValidatorQuery(%q): %v", tt.query, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseValidatorQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatorQueryIsZero(t *testing.T) {
+	for query, want := range map[string]bool{
+		"":             true,
+		"view=full":    true,
+		"view=compact": false,
+		"sort=tokens":  false,
+		"jailed=false": false,
+	} {
+		parsed, err := parseValidatorQuery(newQueryContext(query))
+		if err != nil {
+			t.Fatalf("parseValidatorQuery(%q): %v", query, err)
+		}
+		if got := parsed.IsZero(); got != want {
+			t.Errorf("IsZero(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestValidatorQueryApply(t *testing.T) {
+	validator := func(operator string, moniker string, status stakingtypes.BondStatus, jailed bool, tokens int64, commission string) validatorEntry {
+		return validatorEntry{
+			OperatorAddress: operator,
+			Moniker:         moniker,
+			Status:          status,
+			Jailed:          jailed,
+			Tokens:          sdkmath.NewInt(tokens),
+			Commission:      sdk.MustNewDecFromStr(commission),
+		}
+	}
+	validators := []validatorEntry{
+		validator("a", "Bravo", stakingtypes.Bonded, false, 300, "0.05"),
+		validator("b", "alpha", stakingtypes.Bonded, false, 500, "0.10"),
+		validator("c", "Charlie", stakingtypes.Unbonded, true, 300, "0.05"),
+		validator("d", "delta", stakingtypes.Unbonding, false, 100, "0.20"),
+		validator("e", "echo", stakingtypes.Bonded, false, 300, "0.10"),
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"a", "b", "c", "d", "e"}},
+		{"status=bonded", []string{"a", "b", "e"}},
+		{"status=unbonded,unbonding", []string{"c", "d"}},
+		{"jailed=true", []string{"c"}},
+		{"jailed=false", []string{"a", "b", "d", "e"}},
+		{"min_commission=0.1", []string{"b", "d", "e"}},
+		{"max_commission=0.1", []string{"a", "b", "c", "e"}},
+		{"min_commission=0.1&max_commission=0.1", []string{"b", "e"}},
+		// tokens default to descending; ties keep their original order
+		{"sort=tokens", []string{"b", "a", "c", "e", "d"}},
+		{"sort=tokens&order=asc", []string{"d", "a", "c", "e", "b"}},
+		{"sort=commission", []string{"a", "c", "b", "e", "d"}},
+		{"sort=commission&order=desc", []string{"d", "b", "e", "a", "c"}},
+		{"sort=moniker", []string{"b", "a", "c", "d", "e"}},
+		{"status=bonded&sort=tokens&order=asc", []string{"a", "e", "b"}},
+		{"status=bonded&jailed=true", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := parseValidatorQuery(newQueryContext(tt.query))
+			if err != nil {
+				t.Fatalf("parseValidatorQuery(%q): %v", tt.query, err)
+			}
+
+			got := []string{}
+			for _, validator := range query.Apply(validators) {
+				got = append(got, validator.OperatorAddress)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	if validators[0].OperatorAddress != "a" || validators[4].OperatorAddress != "e" {
+		t.Error("Apply reordered its input")
+	}
+}
+
+func TestServeValidatorListCompact(t *testing.T) {
+	cache, err := NewRistrettoCache(&ristretto.Config{NumCounters: 1e3, MaxCost: 1 << 20, BufferItems: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := echov4.New()
+	e.Logger.SetOutput(io.Discard)
+	s := NewCacheService(e, cache, Config{})
+
+	list := []byte(`{"validators":[` +
+		`{"operator_address":"a","jailed":false,"status":"BOND_STATUS_BONDED","tokens":"100","description":{"moniker":"Alpha"},"commission":{"commission_rates":{"rate":"0.050000000000000000"}}},` +
+		`{"operator_address":"b","jailed":true,"status":"BOND_STATUS_UNBONDED","tokens":"300","description":{"moniker":"Bravo"},"commission":{"commission_rates":{"rate":"0.100000000000000000"}}}` +
+		`],"pagination":{"next_key":null,"total":"2"}}`)
+
+	req := httptest.NewRequest("GET", "/validatorList/cosmoshub-4?view=compact&sort=tokens", nil)
+	rec := httptest.NewRecorder()
+	ctx := e.NewContext(req, rec)
+
+	query, err := parseValidatorQuery(ctx)
+	if err != nil {
+		t.Fatalf("parseValidatorQuery: %v", err)
+	}
+	if err := s.serveValidatorList(ctx, "validatorList.cosmoshub-4", query, func() ([]byte, error) { return list, nil }); err != nil {
+		t.Fatalf("serveValidatorList: %v", err)
+	}
+
+	var resp struct {
+		Validators []map[string]interface{} `json:"validators"`
+		Pagination struct {
+			Total string `json:"total"`
+		} `json:"pagination"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := []map[string]interface{}{
+		{"operator_address": "b", "moniker": "Bravo", "status": "unbonded", "jailed": true, "tokens": "300", "commission": "0.100000000000000000"},
+		{"operator_address": "a", "moniker": "Alpha", "status": "bonded", "jailed": false, "tokens": "100", "commission": "0.050000000000000000"},
+	}
+	if !reflect.DeepEqual(resp.Validators, want) {
+		t.Errorf("validators = %v, want %v", resp.Validators, want)
+	}
+	if resp.Pagination.Total != "2" {
+		t.Errorf("pagination total = %q, want 2", resp.Pagination.Total)
+	}
+}
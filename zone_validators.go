@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	"github.com/cosmos/cosmos-sdk/codec"
+	cdctypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/query"
+	icstypes "github.com/ingenuity-build/quicksilver/x/interchainstaking/types"
+)
+
+// ZoneValidator is a host chain validator as seen by Quicksilver: its staking
+// state joined with the zone's validator record, the protocol's delegation to
+// it and its share of the aggregate intent.
+type ZoneValidator struct {
+	OperatorAddress    string      `json:"operator_address"`
+	Moniker            string      `json:"moniker"`
+	Status             string      `json:"status"`
+	Jailed             bool        `json:"jailed"`
+	Tombstoned         bool        `json:"tombstoned"`
+	Tokens             sdkmath.Int `json:"tokens"`
+	Commission         sdk.Dec     `json:"commission"`
+	ProtocolDelegation sdkmath.Int `json:"protocol_delegation"`
+	IntentPercent      sdk.Dec     `json:"intent_percent"`
+	Score              sdk.Dec     `json:"score"`
+	InZone             bool        `json:"in_zone"`
+}
+
+// ZoneValidatorsResponse lists the validators of a zone. Height is the host
+// chain height the validators were read at; QuicksilverHeight is the height
+// the zone and its delegations were read at.
+type ZoneValidatorsResponse struct {
+	ChainID                 string          `json:"chain_id"`
+	Height                  int64           `json:"height"`
+	QuicksilverHeight       int64           `json:"quicksilver_height"`
+	TotalProtocolDelegation sdkmath.Int     `json:"total_protocol_delegation"`
+	Validators              []ZoneValidator `json:"validators"`
+}
+
+// getZoneValidators joins the cached validator list of chain with the zone's
+// validator records, aggregate intent and protocol delegations. Validators the
+// protocol neither delegates to nor tracks are listed with zero values. The
+// response is reported as read from the host chain state of the list.
+func (s *Service) getZoneValidators(key string, chain ChainConfig) ([]byte, error) {
+	s.Echo.Logger.Infof("getZoneValidators")
+
+	zones, zonesHeight, err := s.queryZones(0)
+	if err != nil {
+		return nil, err
+	}
+	var zone *icstypes.Zone
+	for i := range zones.Zones {
+		if zones.Zones[i].ChainId == chain.ChainID {
+			zone = &zones.Zones[i]
+			break
+		}
+	}
+	if zone == nil {
+		apiErr := sentinelError(ErrUnknownChain)
+		apiErr.Message = fmt.Sprintf("%s: %s is not a Quicksilver zone", ErrUnknownChain, chain.ChainID)
+		return nil, apiErr
+	}
+
+	listKey := validatorListKey(chain.ChainID)
+	list, err := s.cachedData(listKey, func() ([]byte, error) {
+		return s.getValidatorList(listKey, chain, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	meta := s.cachedChainState(listKey).ChainMeta
+	validators, err := decodeValidatorList(list)
+	if err != nil {
+		s.Echo.Logger.Errorf("getZoneValidators: %v - %v", ErrUnmarshalResponse, err)
+		return nil, ErrUnmarshalResponse
+	}
+
+	delegations, err := s.queryProtocolDelegations(chain.ChainID, zonesHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := ZoneValidatorsResponse{
+		ChainID:                 chain.ChainID,
+		Height:                  meta.Height,
+		QuicksilverHeight:       zonesHeight,
+		TotalProtocolDelegation: sdkmath.ZeroInt(),
+		Validators:              []ZoneValidator{},
+	}
+	for _, delegation := range delegations {
+		resp.TotalProtocolDelegation = resp.TotalProtocolDelegation.Add(delegation)
+	}
+
+	intents := zoneIntentPercents(zone.AggregateIntent)
+	records := map[string]*icstypes.Validator{}
+	for _, record := range zone.Validators {
+		records[record.ValoperAddress] = record
+	}
+
+	for _, validator := range validators {
+		joined := ZoneValidator{
+			OperatorAddress:    validator.OperatorAddress,
+			Moniker:            validator.Moniker,
+			Status:             strings.ToLower(strings.TrimPrefix(validator.Status.String(), "BOND_STATUS_")),
+			Jailed:             validator.Jailed,
+			Tokens:             validator.Tokens,
+			Commission:         validator.Commission,
+			ProtocolDelegation: sdkmath.ZeroInt(),
+			IntentPercent:      sdk.ZeroDec(),
+			Score:              sdk.ZeroDec(),
+		}
+		if delegation, ok := delegations[validator.OperatorAddress]; ok {
+			joined.ProtocolDelegation = delegation
+		}
+		if intent, ok := intents[validator.OperatorAddress]; ok {
+			joined.IntentPercent = intent
+		}
+		if record, ok := records[validator.OperatorAddress]; ok {
+			joined.InZone = true
+			joined.Tombstoned = record.Tombstoned
+			if !record.Score.IsNil() {
+				joined.Score = record.Score
+			}
+		}
+		resp.Validators = append(resp.Validators, joined)
+	}
+
+	sort.SliceStable(resp.Validators, func(i, j int) bool {
+		a, b := resp.Validators[i], resp.Validators[j]
+		if !a.ProtocolDelegation.Equal(b.ProtocolDelegation) {
+			return a.ProtocolDelegation.GT(b.ProtocolDelegation)
+		}
+		return a.Tokens.GT(b.Tokens)
+	})
+
+	respdata, err := json.Marshal(resp)
+	if err != nil {
+		s.Echo.Logger.Errorf("getZoneValidators: %v - %v", ErrMarshalResponse, err)
+		return nil, ErrMarshalResponse
+	}
+
+	s.setCachedAt(key, respdata, 5*time.Minute, meta)
+
+	return respdata, nil
+}
+
+// zoneIntentPercents converts the aggregate intent weights of a zone into
+// percentages of their total.
+func zoneIntentPercents(intents icstypes.ValidatorIntents) map[string]sdk.Dec {
+	total := sdk.ZeroDec()
+	for _, intent := range intents {
+		if !intent.Weight.IsNil() {
+			total = total.Add(intent.Weight)
+		}
+	}
+
+	percents := map[string]sdk.Dec{}
+	if !total.IsPositive() {
+		return percents
+	}
+	for _, intent := range intents {
+		if intent.Weight.IsNil() {
+			continue
+		}
+		percents[intent.ValoperAddress] = intent.Weight.Quo(total).MulInt64(100)
+	}
+	return percents
+}
+
+// queryProtocolDelegations returns the amount Quicksilver delegates to each
+// validator of the zone chainId at height, so it agrees with the zone read there.
+func (s *Service) queryProtocolDelegations(chainId string, height int64) (map[string]sdkmath.Int, error) {
+	client, err := NewRPCClient(s.Config.RpcEndpoint, 30*time.Second)
+	if err != nil {
+		s.Echo.Logger.Errorf("queryProtocolDelegations: %v - %v", ErrRPCClientConnection, err)
+		return nil, upstreamError(ErrRPCClientConnection, "quicksilver-rpc", err)
+	}
+
+	interfaceRegistry := cdctypes.NewInterfaceRegistry()
+	icstypes.RegisterInterfaces(interfaceRegistry)
+	marshaler := codec.NewProtoCodec(interfaceRegistry)
+
+	delegations := map[string]sdkmath.Int{}
+	_, err = queryAllPages(client, "/quicksilver.interchainstaking.v1.Query/Delegations", height,
+		func(page *query.PageRequest) []byte {
+			return marshaler.MustMarshal(&icstypes.QueryDelegationsRequest{ChainId: chainId, Pagination: page})
+		},
+		func(value []byte) (*query.PageResponse, error) {
+			pageResponse := icstypes.QueryDelegationsResponse{}
+			if err := marshaler.Unmarshal(value, &pageResponse); err != nil {
+				return nil, err
+			}
+			for _, delegation := range pageResponse.Delegations {
+				amount, ok := delegations[delegation.ValidatorAddress]
+				if !ok {
+					amount = sdkmath.ZeroInt()
+				}
+				delegations[delegation.ValidatorAddress] = amount.Add(delegation.Amount.Amount)
+			}
+			return pageResponse.Pagination, nil
+		},
+	)
+	if err != nil {
+		return nil, s.queryError("queryProtocolDelegations", "quicksilver-rpc", err)
+	}
+	return delegations, nil
+}